@@ -0,0 +1,714 @@
+package rdb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Kinds of problems found when analyzing a query, see Registry.Analyze.
+var (
+	ErrUnknownTable    = errors.New("Unknown table")
+	ErrUnknownColumn   = errors.New("Unknown column")
+	ErrAmbiguousColumn = errors.New("Ambiguous column")
+)
+
+// ResultColumn describes a column of the result of a query.
+type ResultColumn struct {
+	Name     string       // Name of the column in the result
+	Expr     Expr         // Select list expression of the column
+	Database string       // Database of the source column, empty if unknown
+	Table    string       // Table of the source column, empty for computed columns
+	Column   string       // Source column, empty for computed columns
+	Model    string       // Model mapped to the source table, empty if not registered
+	Field    string       // Model field mapped to the source column
+	Kind     reflect.Kind // Kind of the values, reflect.Invalid when unknown
+	Nullable bool         // The column may hold NULL values
+
+	t   *table // Registered table of the source column
+	col column
+}
+
+// WriteTarget describes a table written by an INSERT, REPLACE, UPDATE or
+// DELETE statement and the columns assigned to.
+type WriteTarget struct {
+	Database string         // Database of the table, empty if unknown
+	Table    string         // Name of the table
+	Model    string         // Model mapped to the table, empty if not registered
+	Columns  []ResultColumn // Assigned columns, Expr is the assigned value when a single one is given
+}
+
+// ColumnError describes a column reference of a query which can not be
+// resolved.
+type ColumnError struct {
+	Column string // The column reference as written in SQL
+	Kind   error  // One of the Err* analysis problem kinds
+	msg    string
+}
+
+// Error returns the description of the problem.
+func (e *ColumnError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the kind of the problem.
+func (e *ColumnError) Unwrap() error {
+	return e.Kind
+}
+
+// AnalysisError collects every problem found when analyzing a query.
+// errors.Is reports true for the kind of any of the collected problems.
+type AnalysisError struct {
+	Errors []*ColumnError // Problems in query order
+}
+
+// Error returns the description of the problem when a single one was found,
+// or a list of every problem otherwise.
+func (e *AnalysisError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	msgs := make([]string, len(e.Errors))
+	for i, ce := range e.Errors {
+		msgs[i] = "\t" + ce.Error()
+	}
+	return fmt.Sprintf("%d errors analyzing query:\n%s", len(e.Errors), strings.Join(msgs, "\n"))
+}
+
+// Unwrap returns the collected problems.
+func (e *AnalysisError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, ce := range e.Errors {
+		errs[i] = ce
+	}
+	return errs
+}
+
+// add records a problem of kind found on a column reference.
+func (e *AnalysisError) add(kind error, ref Node, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, &ColumnError{
+		Column: ref.String(),
+		Kind:   kind,
+		msg:    fmt.Sprintf(format, args...),
+	})
+}
+
+// scopeTable is a table reference in the FROM clause of a query.
+type scopeTable struct {
+	db      string
+	name    string
+	alias   string
+	t       *table         // Registered table, nil for derived and unregistered tables
+	derived []ResultColumn // Result columns of a derived table
+	outer   bool           // Rows of the table may be missing in an outer join
+}
+
+// known reports whether every column of the table is known.
+func (s *scopeTable) known() bool {
+	return s.t != nil || s.derived != nil
+}
+
+// ref returns the name the table is referenced by in the query.
+func (s *scopeTable) ref() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// column returns the result column for the column named name of the table.
+func (s *scopeTable) column(name string, expr Expr) (ResultColumn, bool) {
+	if s.derived != nil {
+		for _, rc := range s.derived {
+			if rc.Name == name {
+				rc.Expr = expr
+				rc.Nullable = rc.Nullable || s.outer
+				return rc, true
+			}
+		}
+		return ResultColumn{}, false
+	}
+
+	if s.t == nil {
+		return ResultColumn{Name: name, Expr: expr, Database: s.db, Table: s.name, Column: name}, true
+	}
+	for _, c := range s.t.cols {
+		if c.colName == name {
+			return s.result(c, expr), true
+		}
+	}
+	return ResultColumn{}, false
+}
+
+// result returns the result column for column c of the registered table.
+func (s *scopeTable) result(c column, expr Expr) ResultColumn {
+	return ResultColumn{
+		Name:     c.colName,
+		Expr:     expr,
+		Database: s.t.dbName,
+		Table:    s.t.name,
+		Column:   c.colName,
+		Model:    typeName(s.t.model),
+		Field:    c.fieldName,
+		Kind:     valueKind(s.t.model.FieldByIndex(c.index).Type),
+		Nullable: c.null || s.outer,
+		t:        s.t,
+		col:      c,
+	}
+}
+
+// target returns the write target of the table, without columns.
+func (s *scopeTable) target() WriteTarget {
+	if s.t == nil {
+		return WriteTarget{Database: s.db, Table: s.name}
+	}
+	return WriteTarget{Database: s.t.dbName, Table: s.t.name, Model: typeName(s.t.model)}
+}
+
+// star returns the result columns * expands to for the table.
+func (s *scopeTable) star(expr Expr) []ResultColumn {
+	if s.derived != nil {
+		cols := make([]ResultColumn, len(s.derived))
+		for i, rc := range s.derived {
+			rc.Expr = expr
+			rc.Nullable = rc.Nullable || s.outer
+			cols[i] = rc
+		}
+		return cols
+	}
+
+	// The columns of an unregistered table are not known
+	if s.t == nil {
+		return []ResultColumn{{Name: expr.String(), Expr: expr, Database: s.db, Table: s.name}}
+	}
+	cols := make([]ResultColumn, len(s.t.cols))
+	for i, c := range s.t.cols {
+		cols[i] = s.result(c, expr)
+	}
+	return cols
+}
+
+// analyzer resolves the column references of a SELECT statement against the
+// tables of its FROM clause.
+type analyzer struct {
+	reg    *Registry
+	errs   *AnalysisError
+	tables []*scopeTable
+}
+
+// Analyze reports where each result column of a parsed SELECT statement comes
+// from, resolving the tables of the FROM clause to registered models:
+//  - column references are resolved to their database, table and column and,
+//    for registered tables, to the model field, kind and nullability of the
+//    column. Columns of the inner tables of outer joins are nullable.
+//  - * and tbl.* are expanded to the columns of registered tables and derived
+//    tables. A star over an unregistered table is a single result column.
+//  - other expressions are computed columns. The kind of literals and COUNT
+//    is inferred, the kind of other expressions is reflect.Invalid.
+//
+// Column references of the select list, ON, WHERE, GROUP BY, HAVING and
+// ORDER BY clauses naming an unknown table or a column missing from a
+// registered table, and unqualified column references matching several
+// tables, are reported at once in an *AnalysisError. The result columns are
+// returned along with the error.
+func (reg *Registry) Analyze(stmt *SelectStmt) ([]ResultColumn, error) {
+	a := &analyzer{reg: reg, errs: &AnalysisError{}}
+	cols := a.analyze(stmt)
+	if len(a.errs.Errors) > 0 {
+		return cols, a.errs
+	}
+	return cols, nil
+}
+
+// Analyze reports where each result column of a parsed SELECT statement comes
+// from, resolving tables to models registered with the default Registry.
+func Analyze(stmt *SelectStmt) ([]ResultColumn, error) {
+	return defaultRegistry.Analyze(stmt)
+}
+
+// AnalyzeWrite reports the tables written by a parsed INSERT, REPLACE, UPDATE
+// or DELETE statement and maps the columns assigned by the column list of an
+// INSERT, and the SET and ON DUPLICATE KEY UPDATE clauses, to the fields of
+// registered models. An INSERT without a column list assigns every column of
+// a registered table. Column references which can not be resolved are
+// reported in an *AnalysisError, as with Analyze, and the targets are returned
+// along with the error.
+func (reg *Registry) AnalyzeWrite(stmt Statement) ([]WriteTarget, error) {
+	a := &analyzer{reg: reg, errs: &AnalysisError{}}
+	var targets []WriteTarget
+	switch stmt := stmt.(type) {
+	case *InsertStmt:
+		targets = a.analyzeInsert(stmt)
+	case *UpdateStmt:
+		targets = a.analyzeUpdate(stmt)
+	case *DeleteStmt:
+		targets = a.analyzeDelete(stmt)
+	default:
+		return nil, fmt.Errorf("AnalyzeWrite requires an INSERT, REPLACE, UPDATE or DELETE statement, %q given", stmt)
+	}
+	if len(a.errs.Errors) > 0 {
+		return targets, a.errs
+	}
+	return targets, nil
+}
+
+// AnalyzeWrite reports the tables written by a parsed INSERT, REPLACE, UPDATE
+// or DELETE statement, resolving them to models registered with the default
+// Registry.
+func AnalyzeWrite(stmt Statement) ([]WriteTarget, error) {
+	return defaultRegistry.AnalyzeWrite(stmt)
+}
+
+// analyzeInsert resolves the table and assigned columns of an INSERT or
+// REPLACE statement.
+func (a *analyzer) analyzeInsert(stmt *InsertStmt) []WriteTarget {
+	a.tables = nil
+	a.addTables(stmt.Table, false)
+	st := a.tables[0]
+	w := st.target()
+
+	// The value of each column is only known for a single row of values
+	var row []Expr
+	if len(stmt.Rows) == 1 {
+		row = stmt.Rows[0]
+	}
+	value := func(i int) Expr {
+		if i < len(row) {
+			return row[i]
+		}
+		return nil
+	}
+
+	switch {
+	case len(stmt.Set) > 0:
+		for _, set := range stmt.Set {
+			w.Columns = a.assign(w.Columns, set)
+		}
+	case len(stmt.Columns) > 0:
+		for i, name := range stmt.Columns {
+			if rc, ok := a.column(&ColumnRef{Name: name}); ok {
+				rc.Expr = value(i)
+				w.Columns = append(w.Columns, rc)
+			}
+		}
+	case st.t != nil:
+		for i, c := range st.t.cols {
+			w.Columns = append(w.Columns, st.result(c, value(i)))
+		}
+	}
+	for _, row := range stmt.Rows {
+		for _, e := range row {
+			a.check(e, nil)
+		}
+	}
+
+	for _, set := range stmt.OnDuplicate {
+		w.Columns = a.assign(w.Columns, set)
+	}
+	for _, se := range stmt.Returning {
+		a.selectExpr(se)
+	}
+	if stmt.Select != nil {
+		inner := &analyzer{reg: a.reg, errs: a.errs}
+		inner.analyze(stmt.Select)
+	}
+	return []WriteTarget{w}
+}
+
+// analyzeUpdate resolves the tables and assigned columns of an UPDATE
+// statement. Only tables assigned to are targets.
+func (a *analyzer) analyzeUpdate(stmt *UpdateStmt) []WriteTarget {
+	a.tables = nil
+	for _, ref := range stmt.Tables {
+		a.addTables(ref, false)
+	}
+	for _, ref := range stmt.Tables {
+		a.checkJoins(ref)
+	}
+
+	var cols []ResultColumn
+	for _, set := range stmt.Set {
+		cols = a.assign(cols, set)
+	}
+	a.check(stmt.Where, nil)
+	for _, o := range stmt.OrderBy {
+		a.check(o.Expr, nil)
+	}
+
+	var targets []WriteTarget
+	for _, rc := range cols {
+		i := 0
+		for i < len(targets) && !(targets[i].Database == rc.Database && targets[i].Table == rc.Table) {
+			i++
+		}
+		if i == len(targets) {
+			targets = append(targets, WriteTarget{Database: rc.Database, Table: rc.Table, Model: rc.Model})
+		}
+		targets[i].Columns = append(targets[i].Columns, rc)
+	}
+	return targets
+}
+
+// analyzeDelete resolves the tables deleted from by a DELETE statement.
+func (a *analyzer) analyzeDelete(stmt *DeleteStmt) []WriteTarget {
+	refs := stmt.From
+	if len(stmt.Using) > 0 {
+		refs = stmt.Using
+	}
+	a.tables = nil
+	for _, ref := range refs {
+		a.addTables(ref, false)
+	}
+	for _, ref := range refs {
+		a.checkJoins(ref)
+	}
+	a.check(stmt.Where, nil)
+	for _, o := range stmt.OrderBy {
+		a.check(o.Expr, nil)
+	}
+	for _, se := range stmt.Returning {
+		a.selectExpr(se)
+	}
+
+	if len(stmt.Targets) == 0 {
+		return []WriteTarget{a.tables[0].target()}
+	}
+	var targets []WriteTarget
+	for _, t := range stmt.Targets {
+		qualifier := []string{t.Name}
+		if t.DB != "" {
+			qualifier = []string{t.DB, t.Name}
+		}
+		found := false
+		for _, st := range a.tables {
+			if a.matches(st, qualifier) {
+				targets = append(targets, st.target())
+				found = true
+				break
+			}
+		}
+		if !found {
+			a.errs.add(ErrUnknownTable, t, `Unknown table "%s" in DELETE`, strings.Join(qualifier, "."))
+		}
+	}
+	return targets
+}
+
+// assign resolves the column assigned by set and appends it to cols, unless
+// cols already holds it. The value assigned is checked too.
+func (a *analyzer) assign(cols []ResultColumn, set *Assignment) []ResultColumn {
+	a.check(set.Value, nil)
+	rc, ok := a.column(set.Column)
+	if !ok {
+		return cols
+	}
+	rc.Expr = set.Value
+	for _, c := range cols {
+		if c.Database == rc.Database && c.Table == rc.Table && c.Column == rc.Column {
+			return cols
+		}
+	}
+	return append(cols, rc)
+}
+
+// analyze resolves the result columns of stmt.
+func (a *analyzer) analyze(stmt *SelectStmt) []ResultColumn {
+	a.tables = nil
+	for _, ref := range stmt.From {
+		a.addTables(ref, false)
+	}
+	for _, ref := range stmt.From {
+		a.checkJoins(ref)
+	}
+
+	var cols []ResultColumn
+	aliases := make(map[string]bool)
+	for _, se := range stmt.Columns {
+		cols = append(cols, a.selectExpr(se)...)
+		if se.Alias != "" {
+			aliases[se.Alias] = true
+		}
+	}
+
+	a.check(stmt.Where, nil)
+	for _, e := range stmt.GroupBy {
+		a.check(e, aliases)
+	}
+	a.check(stmt.Having, aliases)
+	for _, o := range stmt.OrderBy {
+		a.check(o.Expr, aliases)
+	}
+	return cols
+}
+
+// addTables adds the tables of a table reference to the scope.
+func (a *analyzer) addTables(ref TableExpr, outer bool) {
+	switch ref := ref.(type) {
+	case *TableName:
+		a.tables = append(a.tables, &scopeTable{
+			db:    ref.DB,
+			name:  ref.Name,
+			alias: ref.Alias,
+			t:     a.reg.findTable(ref.DB, ref.Name),
+			outer: outer,
+		})
+
+	case *DerivedTable:
+		inner := &analyzer{reg: a.reg, errs: a.errs}
+		cols := inner.analyze(ref.Select)
+		if cols == nil {
+			cols = []ResultColumn{}
+		}
+		a.tables = append(a.tables, &scopeTable{name: ref.Alias, alias: ref.Alias, derived: cols, outer: outer})
+
+	case *JoinExpr:
+		left, right := outer, outer
+		switch {
+		case strings.Contains(ref.Join, "LEFT"):
+			right = true
+		case strings.Contains(ref.Join, "RIGHT"):
+			left = true
+		}
+		a.addTables(ref.Left, left)
+		a.addTables(ref.Right, right)
+
+	case *ParenTableExpr:
+		for _, t := range ref.Tables {
+			a.addTables(t, outer)
+		}
+
+	case *OJTableExpr:
+		a.addTables(ref.Table, outer)
+	}
+}
+
+// checkJoins checks the column references of the ON conditions of joins.
+func (a *analyzer) checkJoins(ref TableExpr) {
+	switch ref := ref.(type) {
+	case *JoinExpr:
+		a.checkJoins(ref.Left)
+		a.checkJoins(ref.Right)
+		a.check(ref.On, nil)
+	case *ParenTableExpr:
+		for _, t := range ref.Tables {
+			a.checkJoins(t)
+		}
+	case *OJTableExpr:
+		a.checkJoins(ref.Table)
+	}
+}
+
+// selectExpr returns the result columns of a select list expression.
+func (a *analyzer) selectExpr(se *SelectExpr) []ResultColumn {
+	switch e := se.Expr.(type) {
+	case *StarExpr:
+		var cols []ResultColumn
+		for _, st := range a.tables {
+			if len(e.Qualifier) == 0 || a.matches(st, e.Qualifier) {
+				cols = append(cols, st.star(e)...)
+			}
+		}
+		if len(e.Qualifier) > 0 && cols == nil {
+			a.errs.add(ErrUnknownTable, e, `Unknown table "%s" in "%s"`, strings.Join(e.Qualifier, "."), e)
+		}
+		return cols
+
+	case *ColumnRef:
+		rc, ok := a.column(e)
+		if !ok {
+			rc = ResultColumn{Name: e.Name, Expr: e}
+		}
+		if se.Alias != "" {
+			rc.Name = se.Alias
+		}
+		return []ResultColumn{rc}
+	}
+
+	a.check(se.Expr, nil)
+	rc := ResultColumn{Name: se.Alias, Expr: se.Expr, Nullable: true}
+	if rc.Name == "" {
+		rc.Name = se.Expr.String()
+	}
+	rc.Kind, rc.Nullable = exprKind(se.Expr)
+	return []ResultColumn{rc}
+}
+
+// exprKind infers the kind and nullability of a computed column.
+func exprKind(e Expr) (reflect.Kind, bool) {
+	switch e := e.(type) {
+	case *Literal:
+		switch e.kind {
+		case naturalNumber, integer:
+			return reflect.Int64, false
+		case fixedNumber, floatingPointNumber:
+			return reflect.Float64, false
+		case quotedString, charsetString:
+			return reflect.String, false
+		case hexNumber, bitNumber:
+			return reflect.Slice, false
+		case trueToken, falseToken:
+			return reflect.Bool, false
+		}
+	case *FuncCall:
+		if strings.EqualFold(e.Name, "COUNT") {
+			return reflect.Int64, false
+		}
+	case *ParenExpr:
+		return exprKind(e.X)
+	}
+	return reflect.Invalid, true
+}
+
+// check resolves every column reference of e outside of subqueries, names
+// in aliases are select list aliases and not checked.
+func (a *analyzer) check(e Expr, aliases map[string]bool) {
+	walkExpr(e, func(e Expr) {
+		if ref, ok := e.(*ColumnRef); ok && !(len(ref.Qualifier) == 0 && aliases[ref.Name]) {
+			a.column(ref)
+		}
+	})
+}
+
+// matches reports whether a qualifier, tbl or db.tbl, refers to table st.
+func (a *analyzer) matches(st *scopeTable, qualifier []string) bool {
+	if len(qualifier) == 1 {
+		return st.ref() == qualifier[0]
+	}
+
+	db := st.db
+	if db == "" && st.t != nil {
+		db = st.t.dbName
+	}
+	return st.alias == "" && st.name == qualifier[1] && db == qualifier[0]
+}
+
+// column resolves a column reference to a column of the tables in scope,
+// recording a problem when it can not be resolved.
+func (a *analyzer) column(ref *ColumnRef) (ResultColumn, bool) {
+	if len(ref.Qualifier) > 0 {
+		for _, st := range a.tables {
+			if !a.matches(st, ref.Qualifier) {
+				continue
+			}
+			rc, ok := st.column(ref.Name, ref)
+			if !ok {
+				a.errs.add(ErrUnknownColumn, ref, `Unknown column "%s" in table "%s"`, ref.Name, st.ref())
+			}
+			return rc, ok
+		}
+		a.errs.add(ErrUnknownTable, ref, `Unknown table "%s" in column "%s"`, strings.Join(ref.Qualifier, "."), ref)
+		return ResultColumn{}, false
+	}
+
+	// An unqualified column of a single unregistered table is its column
+	if len(a.tables) == 1 && !a.tables[0].known() {
+		return a.tables[0].column(ref.Name, ref)
+	}
+
+	var found []ResultColumn
+	var in []string
+	known := true
+	for _, st := range a.tables {
+		if !st.known() {
+			known = false
+			continue
+		}
+		if rc, ok := st.column(ref.Name, ref); ok {
+			found = append(found, rc)
+			in = append(in, st.ref())
+		}
+	}
+
+	switch {
+	case len(found) == 1:
+		return found[0], true
+	case len(found) > 1:
+		a.errs.add(ErrAmbiguousColumn, ref, `Column "%s" is ambiguous, it is a column of tables "%s"`,
+			ref.Name, strings.Join(in, `", "`))
+	case known && len(a.tables) > 0:
+		a.errs.add(ErrUnknownColumn, ref, `Unknown column "%s"`, ref.Name)
+	}
+	return ResultColumn{}, false
+}
+
+// findTable returns the registered table named name in database db, or in any
+// database when db is empty and a single registered table is named name.
+func (reg *Registry) findTable(db, name string) *table {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	if db != "" {
+		return reg.dbMap[db][name]
+	}
+
+	var found []string
+	for d, tables := range reg.dbMap {
+		if _, ok := tables[name]; ok {
+			found = append(found, d)
+		}
+	}
+	if len(found) != 1 {
+		return nil
+	}
+	return reg.dbMap[found[0]][name]
+}
+
+// walkExpr calls fn for e and every expression nested in e, except for the
+// expressions of subqueries.
+func walkExpr(e Expr, fn func(Expr)) {
+	if e == nil {
+		return
+	}
+	fn(e)
+
+	switch e := e.(type) {
+	case *FuncCall:
+		for _, arg := range e.Args {
+			walkExpr(arg, fn)
+		}
+		for _, o := range e.OrderBy {
+			walkExpr(o.Expr, fn)
+		}
+	case *UnaryExpr:
+		walkExpr(e.X, fn)
+	case *BinaryExpr:
+		walkExpr(e.L, fn)
+		walkExpr(e.R, fn)
+	case *IsExpr:
+		walkExpr(e.X, fn)
+	case *InExpr:
+		walkExpr(e.X, fn)
+		for _, x := range e.List {
+			walkExpr(x, fn)
+		}
+	case *BetweenExpr:
+		walkExpr(e.X, fn)
+		walkExpr(e.Lo, fn)
+		walkExpr(e.Hi, fn)
+	case *LikeExpr:
+		walkExpr(e.X, fn)
+		walkExpr(e.Pattern, fn)
+		walkExpr(e.Escape, fn)
+	case *CastExpr:
+		walkExpr(e.X, fn)
+	case *ConvertExpr:
+		walkExpr(e.X, fn)
+	case *IntervalExpr:
+		walkExpr(e.X, fn)
+	case *ParenExpr:
+		walkExpr(e.X, fn)
+	case *TupleExpr:
+		for _, x := range e.Exprs {
+			walkExpr(x, fn)
+		}
+	case *CaseExpr:
+		walkExpr(e.Operand, fn)
+		for _, w := range e.Whens {
+			walkExpr(w.Cond, fn)
+			walkExpr(w.Result, fn)
+		}
+		walkExpr(e.Else, fn)
+	}
+}
@@ -0,0 +1,197 @@
+package rdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// analyze parses a SELECT statement and analyzes it against the crud test
+// models.
+func analyze(t *testing.T, query string) ([]ResultColumn, error) {
+	t.Helper()
+	db, _ := newCrudRdb(t)
+	stmt, err := Parse(query)
+	if err != nil {
+		t.Fatalf("Not expecting error parsing %q but got: %s", query, err.Error())
+	}
+	return db.Registry.Analyze(stmt.(*SelectStmt))
+}
+
+func TestAnalyzeResultColumns(t *testing.T) {
+	cols, err := analyze(t, "SELECT DISTINCT users.id, `app`.`users`.email AS contact, COUNT(*) n, m.*, o.total, 'x' "+
+		"FROM app.users LEFT JOIN memberships m ON m.user_id = users.id JOIN orders o ON o.user_id = users.id WHERE users.id > 3")
+	if err != nil {
+		t.Fatalf("Not expecting error on Analyze but got: %s", err.Error())
+	}
+
+	user := typeName(reflect.TypeOf(crudUser{}))
+	membership := typeName(reflect.TypeOf(crudMembership{}))
+	expected := []ResultColumn{
+		{Name: "id", Database: "app", Table: "users", Column: "id", Model: user, Field: "ID", Kind: reflect.Uint32},
+		{Name: "contact", Database: "app", Table: "users", Column: "email", Model: user, Field: "Email", Kind: reflect.String},
+		{Name: "n", Kind: reflect.Int64},
+		{Name: "group_id", Database: "app", Table: "memberships", Column: "group_id", Model: membership, Field: "GroupID", Kind: reflect.Int64, Nullable: true},
+		{Name: "user_id", Database: "app", Table: "memberships", Column: "user_id", Model: membership, Field: "UserID", Kind: reflect.Int64, Nullable: true},
+		{Name: "role", Database: "app", Table: "memberships", Column: "role", Model: membership, Field: "Role", Kind: reflect.String, Nullable: true},
+		{Name: "total", Table: "orders", Column: "total"},
+		{Name: "'x'", Kind: reflect.String},
+	}
+	if len(cols) != len(expected) {
+		t.Fatalf("Expected %d result columns, got %d: %+v", len(expected), len(cols), cols)
+	}
+	for i, e := range expected {
+		c := cols[i]
+		c.Expr, c.t, c.col = nil, nil, column{}
+		if !reflect.DeepEqual(c, e) {
+			t.Errorf("Expected result column %d:\n%+v\nGot:\n%+v", i, e, c)
+		}
+	}
+}
+
+func TestAnalyzeDerivedTable(t *testing.T) {
+	cols, err := analyze(t, "SELECT d.contact, d.* FROM (SELECT email AS contact, name FROM users) d")
+	if err != nil {
+		t.Fatalf("Not expecting error on Analyze but got: %s", err.Error())
+	}
+
+	if len(cols) != 3 {
+		t.Fatalf("Expected 3 result columns, got %+v", cols)
+	}
+	if cols[0].Name != "contact" || cols[0].Field != "Email" || cols[1].Field != "Email" {
+		t.Errorf("Expected contact to map to the Email field, got %+v and %+v", cols[0], cols[1])
+	}
+	if cols[2].Name != "name" || cols[2].Field != "Name" || !cols[2].Nullable {
+		t.Errorf("Expected name to map to the nullable Name field, got %+v", cols[2])
+	}
+}
+
+func TestAnalyzeReportsProblems(t *testing.T) {
+	_, err := analyze(t, "SELECT x.id, u.missing, user_id, id AS uid FROM users u JOIN memberships m ON m.nope = u.id "+
+		"WHERE group_id = 1 ORDER BY uid, other")
+	var ae *AnalysisError
+	if !errors.As(err, &ae) {
+		t.Fatalf("Expected an AnalysisError, got %v", err)
+	}
+
+	expected := []struct {
+		kind error
+		msg  string
+	}{
+		{ErrUnknownColumn, `Unknown column "nope" in table "m"`},
+		{ErrUnknownTable, `Unknown table "x" in column "` + "`x`.`id`" + `"`},
+		{ErrUnknownColumn, `Unknown column "missing" in table "u"`},
+		{ErrUnknownColumn, `Unknown column "other"`},
+	}
+	if len(ae.Errors) != len(expected) {
+		t.Fatalf("Expected %d problems, got %d:\n%s", len(expected), len(ae.Errors), err.Error())
+	}
+	for i, e := range expected {
+		if ae.Errors[i].Kind != e.kind || ae.Errors[i].Error() != e.msg {
+			t.Errorf("Expected problem %d to be %q, got %q", i, e.msg, ae.Errors[i].Error())
+		}
+	}
+
+	_, err = analyze(t, "SELECT role FROM memberships a, memberships b")
+	if !errors.Is(err, ErrAmbiguousColumn) {
+		t.Errorf("Expected ambiguous column error, got %v", err)
+	}
+
+	// The columns of tables which are not registered are not known
+	if _, err = analyze(t, "SELECT anything FROM users, orders"); err != nil {
+		t.Errorf("Not expecting error on Analyze but got: %s", err.Error())
+	}
+}
+
+// analyzeWrite parses a write statement and analyzes it against the crud
+// test models.
+func analyzeWrite(t *testing.T, query string) ([]WriteTarget, error) {
+	t.Helper()
+	db, _ := newCrudRdb(t)
+	stmt, err := Parse(query)
+	if err != nil {
+		t.Fatalf("Not expecting error parsing %q but got: %s", query, err.Error())
+	}
+	return db.Registry.AnalyzeWrite(stmt)
+}
+
+// assigned returns the fields of the assigned columns of w and their values.
+func assigned(w WriteTarget) []string {
+	var fields []string
+	for _, rc := range w.Columns {
+		value := "?"
+		if rc.Expr != nil {
+			value = rc.Expr.String()
+		}
+		fields = append(fields, rc.Field+"="+value)
+	}
+	return fields
+}
+
+func TestAnalyzeWriteTargets(t *testing.T) {
+	user := typeName(reflect.TypeOf(crudUser{}))
+	membership := typeName(reflect.TypeOf(crudMembership{}))
+	tests := []struct {
+		query   string
+		targets []string
+		fields  [][]string
+	}{
+		{"INSERT INTO users (email, name) VALUES (?, NULL) ON DUPLICATE KEY UPDATE name = VALUES(name), id = LAST_INSERT_ID(id)",
+			[]string{user}, [][]string{{"Email=?", "Name=NULL", "ID=LAST_INSERT_ID(`id`)"}}},
+		{"INSERT INTO app.users VALUES (1, 'a', 'b'), (2, 'c', 'd')",
+			[]string{user}, [][]string{{"ID=?", "Email=?", "Name=?"}}},
+		{"REPLACE INTO memberships SET role = 'admin', user_id = 1",
+			[]string{membership}, [][]string{{"Role='admin'", "UserID=1"}}},
+		{"INSERT INTO memberships (group_id, user_id) SELECT 1, id FROM users",
+			[]string{membership}, [][]string{{"GroupID=?", "UserID=?"}}},
+		{"UPDATE users u JOIN memberships m ON m.user_id = u.id SET m.role = u.name, u.email = 'x' WHERE m.group_id = 2",
+			[]string{membership, user}, [][]string{{"Role=`u`.`name`"}, {"Email='x'"}}},
+		{"DELETE m FROM memberships m JOIN users u ON u.id = m.user_id WHERE u.email LIKE ?",
+			[]string{membership}, [][]string{nil}},
+		{"DELETE FROM users WHERE id = 1", []string{user}, [][]string{nil}},
+		{"UPDATE orders SET total = 0", []string{""}, [][]string{{"=0"}}},
+	}
+
+	for _, tt := range tests {
+		targets, err := analyzeWrite(t, tt.query)
+		if err != nil {
+			t.Errorf("Not expecting error analyzing %q but got: %s", tt.query, err.Error())
+			continue
+		}
+		if len(targets) != len(tt.targets) {
+			t.Errorf("Expected %d targets analyzing %q, got %+v", len(tt.targets), tt.query, targets)
+			continue
+		}
+		for i, w := range targets {
+			if w.Model != tt.targets[i] {
+				t.Errorf("Expected target %d of %q to be model %q, got %q", i, tt.query, tt.targets[i], w.Model)
+			}
+			if got := assigned(w); !reflect.DeepEqual(got, tt.fields[i]) {
+				t.Errorf("Expected %q to assign %q, got %q", tt.query, tt.fields[i], got)
+			}
+		}
+	}
+}
+
+func TestAnalyzeWriteReportsProblems(t *testing.T) {
+	_, err := analyzeWrite(t, "UPDATE users SET nickname = 'x', email = other WHERE id = 1")
+	var ae *AnalysisError
+	if !errors.As(err, &ae) {
+		t.Fatalf("Expected an AnalysisError, got %v", err)
+	}
+	if len(ae.Errors) != 2 || ae.Errors[0].Column != "`nickname`" || ae.Errors[1].Column != "`other`" {
+		t.Errorf("Expected unknown columns nickname and other, got:\n%s", err.Error())
+	}
+
+	if _, err := analyzeWrite(t, "INSERT INTO users (id, nickname) VALUES (1, 'x')"); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("Expected unknown column error, got %v", err)
+	}
+	if _, err := analyzeWrite(t, "DELETE x FROM users"); !errors.Is(err, ErrUnknownTable) {
+		t.Errorf("Expected unknown table error, got %v", err)
+	}
+
+	stmt, _ := Parse("SELECT id FROM users")
+	if _, err := AnalyzeWrite(stmt); err == nil {
+		t.Errorf("Expected error analyzing a SELECT statement as a write")
+	}
+}
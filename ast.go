@@ -0,0 +1,740 @@
+package rdb
+
+import (
+	"strings"
+)
+
+// Node is a node of the syntax tree of a parsed statement. String returns the
+// SQL of the node, which parses back to an identical node.
+type Node interface {
+	String() string
+}
+
+// Statement is a parsed SQL statement.
+type Statement interface {
+	Node
+	statement()
+}
+
+// Expr is an expression.
+type Expr interface {
+	Node
+	expr()
+}
+
+// TableExpr is a table reference of a FROM clause.
+type TableExpr interface {
+	Node
+	tableExpr()
+}
+
+// SelectStmt is a SELECT statement.
+type SelectStmt struct {
+	Modifiers []string      // Optimizer hints, DISTINCT, SQL_CALC_FOUND_ROWS and other modifiers
+	Columns   []*SelectExpr // Select list
+	From      []TableExpr   // Comma separated table references
+	Where     Expr
+	GroupBy   []Expr
+	Rollup    bool // GROUP BY ... WITH ROLLUP
+	Having    Expr
+	OrderBy   []*OrderExpr
+	Limit     *Limit
+	Locks     []*Lock
+}
+
+// SelectExpr is an expression of a select list and its alias.
+type SelectExpr struct {
+	Expr  Expr
+	Alias string
+}
+
+// OrderExpr is an expression of an ORDER BY clause.
+type OrderExpr struct {
+	Expr Expr
+	Desc bool
+}
+
+// Limit is a LIMIT clause, Offset is nil when not given.
+type Limit struct {
+	Count  Expr
+	Offset Expr
+}
+
+// Lock is a locking clause of a SELECT statement.
+type Lock struct {
+	Mode   string   // FOR UPDATE, FOR SHARE or LOCK IN SHARE MODE
+	Tables []string // Tables of the OF option
+	Wait   string   // NOWAIT, SKIP LOCKED or empty
+}
+
+// InsertStmt is an INSERT or REPLACE statement. Exactly one of Rows, Set and
+// Select holds the values inserted.
+type InsertStmt struct {
+	Replace     bool     // REPLACE rather than INSERT
+	Modifiers   []string // Optimizer hints, LOW_PRIORITY, DELAYED, HIGH_PRIORITY and IGNORE
+	Table       *TableName
+	Columns     []string // Column list, empty when not given
+	Rows        [][]Expr // INSERT ... VALUES
+	Set         []*Assignment
+	Select      *SelectStmt // INSERT ... SELECT
+	OnDuplicate []*Assignment
+	Returning   []*SelectExpr
+}
+
+// UpdateStmt is an UPDATE statement of one or more tables.
+type UpdateStmt struct {
+	Modifiers []string // Optimizer hints, LOW_PRIORITY and IGNORE
+	Tables    []TableExpr
+	Set       []*Assignment
+	Where     Expr
+	OrderBy   []*OrderExpr
+	Limit     *Limit
+}
+
+// DeleteStmt is a DELETE statement. A single-table delete only has From, a
+// multiple-table delete names the tables deleted from in Targets and joins
+// them in From, or in Using for DELETE FROM ... USING.
+type DeleteStmt struct {
+	Modifiers []string // Optimizer hints, LOW_PRIORITY, QUICK and IGNORE
+	Targets   []*TableName
+	From      []TableExpr
+	Using     []TableExpr
+	Where     Expr
+	OrderBy   []*OrderExpr
+	Limit     *Limit
+	Returning []*SelectExpr
+}
+
+// Assignment is the assignment of a value to a column in a SET or ON
+// DUPLICATE KEY UPDATE clause.
+type Assignment struct {
+	Column *ColumnRef
+	Value  Expr
+}
+
+// TableName is a table reference by name, with its optional partitions,
+// alias and index hints.
+type TableName struct {
+	DB         string
+	Name       string
+	Partitions []string
+	Alias      string
+	Hints      []*IndexHint
+}
+
+// IndexHint is an index hint of a table reference, such as
+// USE INDEX FOR JOIN (idx).
+type IndexHint struct {
+	Type    string // USE INDEX, IGNORE KEY, ...
+	For     string // FOR JOIN, FOR ORDER BY or FOR GROUP BY, empty if not given
+	Indexes []string
+}
+
+// DerivedTable is a subquery in a FROM clause.
+type DerivedTable struct {
+	Select *SelectStmt
+	Alias  string
+}
+
+// JoinExpr is the join of two table references.
+type JoinExpr struct {
+	Left  TableExpr
+	Join  string // JOIN, LEFT OUTER JOIN, STRAIGHT_JOIN, ...
+	Right TableExpr
+	On    Expr
+	Using []string
+}
+
+// ParenTableExpr is a parenthesized list of table references.
+type ParenTableExpr struct {
+	Tables []TableExpr
+}
+
+// OJTableExpr is an ODBC outer join, { OJ table_reference }.
+type OJTableExpr struct {
+	Table TableExpr
+}
+
+// ColumnRef is a reference to a column, qualified by a table and database.
+type ColumnRef struct {
+	Qualifier []string // [db, ]tbl, empty for unqualified columns
+	Name      string
+}
+
+// StarExpr is * or tbl.*, in a select list or a COUNT(*).
+type StarExpr struct {
+	Qualifier []string
+}
+
+// Literal is a string, number, hexadecimal, bit, boolean or NULL literal as
+// written in the statement, or DEFAULT. Decoded returns its value.
+type Literal struct {
+	Value string
+	kind  token
+}
+
+// Param is a ? placeholder or a :name parameter.
+type Param struct {
+	Name string // Empty for ? placeholders
+}
+
+// Variable is a user variable, @name, or a system variable, @@name.
+type Variable struct {
+	Name string // The variable as written, including its @ or @@ prefix
+}
+
+// FuncCall is a call of a function. OrderBy and Separator are only set for
+// GROUP_CONCAT.
+type FuncCall struct {
+	Name      string
+	Distinct  bool
+	Args      []Expr
+	OrderBy   []*OrderExpr
+	Separator *Literal
+}
+
+// UnaryExpr is a prefix operator applied to an expression.
+type UnaryExpr struct {
+	Op string
+	X  Expr
+}
+
+// BinaryExpr is an infix operator applied to two expressions.
+type BinaryExpr struct {
+	Op   string
+	L, R Expr
+}
+
+// IsExpr is x IS [NOT] NULL, TRUE, FALSE or UNKNOWN.
+type IsExpr struct {
+	X     Expr
+	Not   bool
+	Value string
+}
+
+// InExpr is x [NOT] IN (list) or x [NOT] IN (subquery).
+type InExpr struct {
+	X      Expr
+	Not    bool
+	List   []Expr
+	Select *SelectStmt
+}
+
+// BetweenExpr is x [NOT] BETWEEN lo AND hi.
+type BetweenExpr struct {
+	X      Expr
+	Not    bool
+	Lo, Hi Expr
+}
+
+// LikeExpr is x [NOT] LIKE pattern [ESCAPE escape].
+type LikeExpr struct {
+	X       Expr
+	Not     bool
+	Pattern Expr
+	Escape  Expr
+}
+
+// CastExpr is CAST(x AS type).
+type CastExpr struct {
+	X    Expr
+	Type string // The type as written, such as UNSIGNED or DECIMAL(10, 2)
+}
+
+// ConvertExpr is CONVERT(x, type) or CONVERT(x USING charset).
+type ConvertExpr struct {
+	X       Expr
+	Type    string // The type as written, empty when converting to Charset
+	Charset string
+}
+
+// IntervalExpr is INTERVAL x unit, an operand of date arithmetic.
+type IntervalExpr struct {
+	X    Expr
+	Unit string // DAY, HOUR_MINUTE and other units in upper case
+}
+
+// TemporalLiteral is a DATE, TIME or TIMESTAMP literal, DATE '2020-01-01'.
+type TemporalLiteral struct {
+	Type  string // DATE, TIME or TIMESTAMP
+	Value *Literal
+}
+
+// ParenExpr is a parenthesized expression.
+type ParenExpr struct {
+	X Expr
+}
+
+// TupleExpr is a row constructor, (a, b).
+type TupleExpr struct {
+	Exprs []Expr
+}
+
+// Subquery is a parenthesized SELECT statement used as an expression.
+type Subquery struct {
+	Select *SelectStmt
+}
+
+// ExistsExpr is EXISTS (subquery).
+type ExistsExpr struct {
+	Select *SelectStmt
+}
+
+// CaseExpr is CASE [operand] WHEN ... THEN ... [ELSE ...] END.
+type CaseExpr struct {
+	Operand Expr
+	Whens   []*When
+	Else    Expr
+}
+
+// When is a WHEN ... THEN ... branch of a CASE expression.
+type When struct {
+	Cond   Expr
+	Result Expr
+}
+
+func (*SelectStmt) statement() {}
+func (*InsertStmt) statement() {}
+func (*UpdateStmt) statement() {}
+func (*DeleteStmt) statement() {}
+
+func (*TableName) tableExpr()      {}
+func (*DerivedTable) tableExpr()   {}
+func (*JoinExpr) tableExpr()       {}
+func (*ParenTableExpr) tableExpr() {}
+func (*OJTableExpr) tableExpr()    {}
+
+func (*ColumnRef) expr()       {}
+func (*StarExpr) expr()        {}
+func (*Literal) expr()         {}
+func (*Param) expr()           {}
+func (*Variable) expr()        {}
+func (*FuncCall) expr()        {}
+func (*UnaryExpr) expr()       {}
+func (*BinaryExpr) expr()      {}
+func (*IsExpr) expr()          {}
+func (*InExpr) expr()          {}
+func (*BetweenExpr) expr()     {}
+func (*LikeExpr) expr()        {}
+func (*CastExpr) expr()        {}
+func (*ConvertExpr) expr()     {}
+func (*IntervalExpr) expr()    {}
+func (*TemporalLiteral) expr() {}
+func (*ParenExpr) expr()       {}
+func (*TupleExpr) expr()       {}
+func (*Subquery) expr()        {}
+func (*ExistsExpr) expr()      {}
+func (*CaseExpr) expr()        {}
+
+// String returns the SQL of the statement.
+func (s *SelectStmt) String() string {
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	for _, m := range s.Modifiers {
+		b.WriteString(m + " ")
+	}
+
+	cols := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		cols[i] = c.String()
+	}
+	b.WriteString(strings.Join(cols, ", "))
+
+	if len(s.From) > 0 {
+		b.WriteString(" FROM " + joinNodes(s.From))
+	}
+	if s.Where != nil {
+		b.WriteString(" WHERE " + s.Where.String())
+	}
+	if len(s.GroupBy) > 0 {
+		b.WriteString(" GROUP BY " + joinNodes(s.GroupBy))
+		if s.Rollup {
+			b.WriteString(" WITH ROLLUP")
+		}
+	}
+	if s.Having != nil {
+		b.WriteString(" HAVING " + s.Having.String())
+	}
+	if len(s.OrderBy) > 0 {
+		b.WriteString(" ORDER BY " + joinNodes(s.OrderBy))
+	}
+	if s.Limit != nil {
+		b.WriteString(" " + s.Limit.String())
+	}
+	for _, l := range s.Locks {
+		b.WriteString(" " + l.String())
+	}
+	return b.String()
+}
+
+// String returns the SQL of the select list expression.
+func (e *SelectExpr) String() string {
+	if e.Alias == "" {
+		return e.Expr.String()
+	}
+	return e.Expr.String() + " AS " + quoteIdent(e.Alias)
+}
+
+// String returns the SQL of the ORDER BY expression.
+func (e *OrderExpr) String() string {
+	if e.Desc {
+		return e.Expr.String() + " DESC"
+	}
+	return e.Expr.String()
+}
+
+// String returns the SQL of the locking clause.
+func (l *Lock) String() string {
+	s := l.Mode
+	if len(l.Tables) > 0 {
+		s += " OF " + identList(l.Tables)
+	}
+	if l.Wait != "" {
+		s += " " + l.Wait
+	}
+	return s
+}
+
+// String returns the SQL of the LIMIT clause.
+func (l *Limit) String() string {
+	if l.Offset == nil {
+		return "LIMIT " + l.Count.String()
+	}
+	return "LIMIT " + l.Count.String() + " OFFSET " + l.Offset.String()
+}
+
+// String returns the SQL of the statement.
+func (s *InsertStmt) String() string {
+	var b strings.Builder
+	if s.Replace {
+		b.WriteString("REPLACE ")
+	} else {
+		b.WriteString("INSERT ")
+	}
+	for _, m := range s.Modifiers {
+		b.WriteString(m + " ")
+	}
+	b.WriteString("INTO " + s.Table.String())
+	if len(s.Columns) > 0 {
+		b.WriteString(" (" + identList(s.Columns) + ")")
+	}
+
+	switch {
+	case s.Select != nil:
+		b.WriteString(" " + s.Select.String())
+	case len(s.Set) > 0:
+		b.WriteString(" SET " + joinNodes(s.Set))
+	default:
+		rows := make([]string, len(s.Rows))
+		for i, row := range s.Rows {
+			rows[i] = "(" + joinNodes(row) + ")"
+		}
+		b.WriteString(" VALUES " + strings.Join(rows, ", "))
+	}
+
+	if len(s.OnDuplicate) > 0 {
+		b.WriteString(" ON DUPLICATE KEY UPDATE " + joinNodes(s.OnDuplicate))
+	}
+	if len(s.Returning) > 0 {
+		b.WriteString(" RETURNING " + joinNodes(s.Returning))
+	}
+	return b.String()
+}
+
+// String returns the SQL of the statement.
+func (s *UpdateStmt) String() string {
+	var b strings.Builder
+	b.WriteString("UPDATE ")
+	for _, m := range s.Modifiers {
+		b.WriteString(m + " ")
+	}
+	b.WriteString(joinNodes(s.Tables) + " SET " + joinNodes(s.Set))
+	if s.Where != nil {
+		b.WriteString(" WHERE " + s.Where.String())
+	}
+	if len(s.OrderBy) > 0 {
+		b.WriteString(" ORDER BY " + joinNodes(s.OrderBy))
+	}
+	if s.Limit != nil {
+		b.WriteString(" " + s.Limit.String())
+	}
+	return b.String()
+}
+
+// String returns the SQL of the statement.
+func (s *DeleteStmt) String() string {
+	var b strings.Builder
+	b.WriteString("DELETE ")
+	for _, m := range s.Modifiers {
+		b.WriteString(m + " ")
+	}
+	switch {
+	case len(s.Using) > 0:
+		b.WriteString("FROM " + joinNodes(s.Targets) + " USING " + joinNodes(s.Using))
+	case len(s.Targets) > 0:
+		b.WriteString(joinNodes(s.Targets) + " FROM " + joinNodes(s.From))
+	default:
+		b.WriteString("FROM " + joinNodes(s.From))
+	}
+	if s.Where != nil {
+		b.WriteString(" WHERE " + s.Where.String())
+	}
+	if len(s.OrderBy) > 0 {
+		b.WriteString(" ORDER BY " + joinNodes(s.OrderBy))
+	}
+	if s.Limit != nil {
+		b.WriteString(" " + s.Limit.String())
+	}
+	if len(s.Returning) > 0 {
+		b.WriteString(" RETURNING " + joinNodes(s.Returning))
+	}
+	return b.String()
+}
+
+// String returns the SQL of the assignment.
+func (a *Assignment) String() string {
+	return a.Column.String() + " = " + a.Value.String()
+}
+
+// String returns the SQL of the table reference.
+func (t *TableName) String() string {
+	s := quoteIdent(t.Name)
+	if t.DB != "" {
+		s = quoteIdent(t.DB) + "." + s
+	}
+	if len(t.Partitions) > 0 {
+		s += " PARTITION (" + identList(t.Partitions) + ")"
+	}
+	if t.Alias != "" {
+		s += " AS " + quoteIdent(t.Alias)
+	}
+	for _, h := range t.Hints {
+		s += " " + h.String()
+	}
+	return s
+}
+
+// String returns the SQL of the index hint.
+func (h *IndexHint) String() string {
+	s := h.Type
+	if h.For != "" {
+		s += " " + h.For
+	}
+	return s + " (" + identList(h.Indexes) + ")"
+}
+
+// String returns the SQL of the derived table.
+func (t *DerivedTable) String() string {
+	return "(" + t.Select.String() + ") AS " + quoteIdent(t.Alias)
+}
+
+// String returns the SQL of the join.
+func (j *JoinExpr) String() string {
+	s := j.Left.String() + " " + j.Join + " " + j.Right.String()
+	if j.On != nil {
+		s += " ON " + j.On.String()
+	}
+	if len(j.Using) > 0 {
+		s += " USING (" + identList(j.Using) + ")"
+	}
+	return s
+}
+
+// String returns the SQL of the parenthesized table references.
+func (t *ParenTableExpr) String() string {
+	return "(" + joinNodes(t.Tables) + ")"
+}
+
+// String returns the SQL of the ODBC outer join.
+func (t *OJTableExpr) String() string {
+	return "{ OJ " + t.Table.String() + " }"
+}
+
+// String returns the SQL of the column reference.
+func (c *ColumnRef) String() string {
+	return qualified(c.Qualifier, quoteIdent(c.Name))
+}
+
+// String returns the SQL of the star expression.
+func (s *StarExpr) String() string {
+	return qualified(s.Qualifier, "*")
+}
+
+// String returns the literal as written.
+func (l *Literal) String() string {
+	return l.Value
+}
+
+// Decoded returns the value of the literal: an int64, uint64 or float64 for
+// numbers, a string with its escape sequences decoded for strings, a []byte
+// for hexadecimal and bit literals, a bool for TRUE and FALSE, and nil for
+// NULL. DEFAULT and numbers out of range return an error.
+func (l *Literal) Decoded() (interface{}, error) {
+	return decodeLiteral(l.kind, l.Value)
+}
+
+// String returns the SQL of the parameter.
+func (p *Param) String() string {
+	if p.Name == "" {
+		return "?"
+	}
+	return ":" + p.Name
+}
+
+// String returns the variable as written.
+func (v *Variable) String() string {
+	return v.Name
+}
+
+// String returns the SQL of the function call.
+func (f *FuncCall) String() string {
+	s := f.Name + "("
+	if f.Distinct {
+		s += "DISTINCT "
+	}
+	s += joinNodes(f.Args)
+	if len(f.OrderBy) > 0 {
+		s += " ORDER BY " + joinNodes(f.OrderBy)
+	}
+	if f.Separator != nil {
+		s += " SEPARATOR " + f.Separator.String()
+	}
+	return s + ")"
+}
+
+// String returns the SQL of the unary expression.
+func (u *UnaryExpr) String() string {
+	x := u.X.String()
+	switch {
+	case u.Op == notStmt || u.Op == "BINARY":
+		return u.Op + " " + x
+	case (u.Op == "-" || u.Op == "+") && strings.ContainsAny(x[:1], "+-.0123456789"):
+		// Keep the operator from being lexed as the sign of a number
+		return u.Op + " " + x
+	}
+	return u.Op + x
+}
+
+// String returns the SQL of the binary expression.
+func (e *BinaryExpr) String() string {
+	return e.L.String() + " " + e.Op + " " + e.R.String()
+}
+
+// String returns the SQL of the IS expression.
+func (e *IsExpr) String() string {
+	return e.X.String() + " IS " + not(e.Not) + e.Value
+}
+
+// String returns the SQL of the IN expression.
+func (e *InExpr) String() string {
+	if e.Select != nil {
+		return e.X.String() + " " + not(e.Not) + "IN (" + e.Select.String() + ")"
+	}
+	return e.X.String() + " " + not(e.Not) + "IN (" + joinNodes(e.List) + ")"
+}
+
+// String returns the SQL of the BETWEEN expression.
+func (e *BetweenExpr) String() string {
+	return e.X.String() + " " + not(e.Not) + "BETWEEN " + e.Lo.String() + " AND " + e.Hi.String()
+}
+
+// String returns the SQL of the LIKE expression.
+func (e *LikeExpr) String() string {
+	s := e.X.String() + " " + not(e.Not) + "LIKE " + e.Pattern.String()
+	if e.Escape != nil {
+		s += " ESCAPE " + e.Escape.String()
+	}
+	return s
+}
+
+// String returns the SQL of the cast.
+func (e *CastExpr) String() string {
+	return "CAST(" + e.X.String() + " AS " + e.Type + ")"
+}
+
+// String returns the SQL of the conversion.
+func (e *ConvertExpr) String() string {
+	if e.Type == "" {
+		return "CONVERT(" + e.X.String() + " USING " + e.Charset + ")"
+	}
+	return "CONVERT(" + e.X.String() + ", " + e.Type + ")"
+}
+
+// String returns the SQL of the interval.
+func (e *IntervalExpr) String() string {
+	return "INTERVAL " + e.X.String() + " " + e.Unit
+}
+
+// String returns the SQL of the temporal literal.
+func (l *TemporalLiteral) String() string {
+	return l.Type + " " + l.Value.String()
+}
+
+// String returns the SQL of the parenthesized expression.
+func (e *ParenExpr) String() string {
+	return "(" + e.X.String() + ")"
+}
+
+// String returns the SQL of the row constructor.
+func (e *TupleExpr) String() string {
+	return "(" + joinNodes(e.Exprs) + ")"
+}
+
+// String returns the SQL of the subquery.
+func (e *Subquery) String() string {
+	return "(" + e.Select.String() + ")"
+}
+
+// String returns the SQL of the EXISTS expression.
+func (e *ExistsExpr) String() string {
+	return "EXISTS (" + e.Select.String() + ")"
+}
+
+// String returns the SQL of the CASE expression.
+func (e *CaseExpr) String() string {
+	s := "CASE "
+	if e.Operand != nil {
+		s += e.Operand.String() + " "
+	}
+	for _, w := range e.Whens {
+		s += "WHEN " + w.Cond.String() + " THEN " + w.Result.String() + " "
+	}
+	if e.Else != nil {
+		s += "ELSE " + e.Else.String() + " "
+	}
+	return s + "END"
+}
+
+// joinNodes returns the comma separated SQL of nodes.
+func joinNodes[N Node](nodes []N) string {
+	s := make([]string, len(nodes))
+	for i, n := range nodes {
+		s[i] = n.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// identList returns the quoted, comma separated identifiers of names.
+func identList(names []string) string {
+	s := make([]string, len(names))
+	for i, n := range names {
+		s[i] = quoteIdent(n)
+	}
+	return strings.Join(s, ", ")
+}
+
+// qualified returns name qualified by the quoted identifiers of qualifier.
+func qualified(qualifier []string, name string) string {
+	for i := len(qualifier) - 1; i >= 0; i-- {
+		name = quoteIdent(qualifier[i]) + "." + name
+	}
+	return name
+}
+
+// not returns "NOT " when negated.
+func not(negated bool) string {
+	if negated {
+		return "NOT "
+	}
+	return ""
+}
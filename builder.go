@@ -0,0 +1,467 @@
+package rdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cond is a condition of a query composed with a SelectBuilder, see Raw.
+type Cond interface {
+	// build returns the SQL of the condition and its arguments, resolving
+	// models against the query built by b.
+	build(b *SelectBuilder) (string, []interface{}, error)
+}
+
+// rawCond is a condition written in SQL.
+type rawCond struct {
+	sql  string
+	args []interface{}
+}
+
+// Raw returns a condition written in SQL, with a ? placeholder for each of
+// args. The SQL is emitted as written, enclosed in parentheses when combined
+// with other conditions.
+func Raw(sql string, args ...interface{}) Cond {
+	return rawCond{sql: sql, args: args}
+}
+
+func (c rawCond) build(b *SelectBuilder) (string, []interface{}, error) {
+	l := lex(c.sql)
+	n := 0
+	for _, it := range l.all() {
+		if it.Token == placeholder {
+			n++
+		}
+	}
+	if err := l.Err(); err != nil {
+		return "", nil, err
+	}
+	if n != len(c.args) {
+		return "", nil, fmt.Errorf(`Raw condition "%s" has %d placeholders but %d arguments`, c.sql, n, len(c.args))
+	}
+	return c.sql, c.args, nil
+}
+
+// SelectBuilder composes a SELECT statement of the columns of a registered
+// model, joining other registered models. Tables and columns are resolved
+// from the registry and always emitted database qualified. The first problem
+// found while composing the statement is reported by SQL.
+type SelectBuilder struct {
+	reg       *Registry
+	err       error
+	modifiers []token
+	from      *builderTable
+	joins     []*builderJoin
+	where     []Cond
+	orderBy   []string
+	limit     string
+}
+
+// builderTable is a table of a query composed with a SelectBuilder.
+type builderTable struct {
+	t     *table
+	hints []string
+}
+
+// builderJoin is a table joined to a query composed with a SelectBuilder.
+type builderJoin struct {
+	builderTable
+	join token
+	on   []Cond
+}
+
+// Select returns a SelectBuilder of the columns of a model registered with
+// the default Registry.
+func Select(model interface{}) *SelectBuilder {
+	return defaultRegistry.Select(model)
+}
+
+// Select returns a SelectBuilder of the columns of a registered model, given
+// as a struct value or a pointer to a struct.
+func (reg *Registry) Select(model interface{}) *SelectBuilder {
+	b := &SelectBuilder{reg: reg}
+	t, err := reg.table(model)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.from = &builderTable{t: t}
+	return b
+}
+
+// Distinct adds the DISTINCT modifier.
+func (b *SelectBuilder) Distinct() *SelectBuilder {
+	return b.modifier(distinctToken)
+}
+
+// HighPriority adds the HIGH_PRIORITY modifier.
+func (b *SelectBuilder) HighPriority() *SelectBuilder {
+	return b.modifier(highPriorityToken)
+}
+
+// CalcFoundRows adds the SQL_CALC_FOUND_ROWS modifier.
+func (b *SelectBuilder) CalcFoundRows() *SelectBuilder {
+	return b.modifier(sqlCalcFoundRowsToken)
+}
+
+// modifier adds a select modifier once.
+func (b *SelectBuilder) modifier(t token) *SelectBuilder {
+	if !hasToken(b.modifiers, t) {
+		b.modifiers = append(b.modifiers, t)
+	}
+	return b
+}
+
+// Join joins a registered model with JOIN. Without conditions the tables are
+// joined on the foreign key relating the model to a single model of the
+// query, see Registry.ForeignKeys.
+func (b *SelectBuilder) Join(model interface{}, on ...Cond) *SelectBuilder {
+	return b.join(joinToken, model, on)
+}
+
+// LeftJoin joins a registered model with LEFT JOIN, see Join.
+func (b *SelectBuilder) LeftJoin(model interface{}, on ...Cond) *SelectBuilder {
+	return b.join(leftJoinToken, model, on)
+}
+
+// RightJoin joins a registered model with RIGHT JOIN, see Join.
+func (b *SelectBuilder) RightJoin(model interface{}, on ...Cond) *SelectBuilder {
+	return b.join(rightJoinToken, model, on)
+}
+
+// StraightJoin joins a registered model with STRAIGHT_JOIN, see Join.
+func (b *SelectBuilder) StraightJoin(model interface{}, on ...Cond) *SelectBuilder {
+	return b.join(straightJoinToken, model, on)
+}
+
+// CrossJoin joins a registered model with CROSS JOIN, without a condition.
+func (b *SelectBuilder) CrossJoin(model interface{}) *SelectBuilder {
+	return b.join(crossJoinToken, model, nil)
+}
+
+// NaturalJoin joins a registered model with NATURAL JOIN.
+func (b *SelectBuilder) NaturalJoin(model interface{}) *SelectBuilder {
+	return b.join(naturalJoinToken, model, nil)
+}
+
+// NaturalLeftJoin joins a registered model with NATURAL LEFT JOIN.
+func (b *SelectBuilder) NaturalLeftJoin(model interface{}) *SelectBuilder {
+	return b.join(naturalLeftJoinToken, model, nil)
+}
+
+// NaturalRightJoin joins a registered model with NATURAL RIGHT JOIN.
+func (b *SelectBuilder) NaturalRightJoin(model interface{}) *SelectBuilder {
+	return b.join(naturalRightJoinToken, model, nil)
+}
+
+// join adds the table of model to the query with join.
+func (b *SelectBuilder) join(join token, model interface{}, on []Cond) *SelectBuilder {
+	if b.err != nil {
+		return b
+	}
+	t, err := b.reg.table(model)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	if b.table(t) != nil {
+		b.err = fmt.Errorf(`Select can not join model "%s" which is already in the query`, typeName(t.model))
+		return b
+	}
+
+	j := &builderJoin{builderTable: builderTable{t: t}, join: join, on: on}
+	if len(on) == 0 && !isNaturalJoin(join) && join != crossJoinToken {
+		cond, err := b.joinCond(t)
+		if err != nil {
+			b.err = err
+			return b
+		}
+		j.on = []Cond{cond}
+	}
+	b.joins = append(b.joins, j)
+	return b
+}
+
+// joinCond returns the condition joining table t on the single foreign key
+// relating it to a table of the query.
+func (b *SelectBuilder) joinCond(t *table) (Cond, error) {
+	b.reg.mu.Lock()
+	graph, err := b.reg.resolve()
+	b.reg.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var fks []foreignKey
+	for _, fk := range graph[t] {
+		if b.table(fk.to) != nil {
+			fks = append(fks, fk)
+		}
+	}
+	for _, qt := range b.tables() {
+		for _, fk := range graph[qt.t] {
+			if fk.to == t {
+				fks = append(fks, fk)
+			}
+		}
+	}
+
+	switch len(fks) {
+	case 0:
+		return nil, fmt.Errorf(`Select can not join model "%s", no foreign key relates it to the models of the query`,
+			typeName(t.model))
+	case 1:
+		fk := fks[0]
+		return Raw(qualifiedColumn(fk.from, fk.fkCol) + " = " + qualifiedColumn(fk.to, fk.refCol)), nil
+	}
+	return nil, fmt.Errorf(`Select can not join model "%s", %d foreign keys relate it to the models of the query`,
+		typeName(t.model), len(fks))
+}
+
+// UseIndex adds a USE INDEX hint to the table last added to the query.
+func (b *SelectBuilder) UseIndex(indexes ...string) *SelectBuilder {
+	return b.hint(useIndexToken, indexes)
+}
+
+// ForceIndex adds a FORCE INDEX hint to the table last added to the query.
+func (b *SelectBuilder) ForceIndex(indexes ...string) *SelectBuilder {
+	return b.hint(forceIndexToken, indexes)
+}
+
+// IgnoreIndex adds an IGNORE INDEX hint to the table last added to the query.
+func (b *SelectBuilder) IgnoreIndex(indexes ...string) *SelectBuilder {
+	return b.hint(ignoreIndexToken, indexes)
+}
+
+// hint adds an index hint to the table last added to the query.
+func (b *SelectBuilder) hint(t token, indexes []string) *SelectBuilder {
+	if b.err != nil {
+		return b
+	}
+	if len(indexes) == 0 && t != useIndexToken {
+		b.err = fmt.Errorf("Select requires indexes for %s", keywordText[t])
+		return b
+	}
+	qt := b.from
+	if len(b.joins) > 0 {
+		qt = &b.joins[len(b.joins)-1].builderTable
+	}
+	qt.hints = append(qt.hints, keywordText[t]+" ("+identList(indexes)+")")
+	return b
+}
+
+// Where adds conditions to the WHERE clause, every condition must hold.
+func (b *SelectBuilder) Where(conds ...Cond) *SelectBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+// OrderBy adds fields to the ORDER BY clause. A field is named Field for a
+// field of the selected model, or Model.Field for a field of any model of the
+// query, and may be followed by ASC or DESC, as in "Post.Title DESC".
+func (b *SelectBuilder) OrderBy(fields ...string) *SelectBuilder {
+	for _, f := range fields {
+		if b.err != nil {
+			return b
+		}
+		name, dir := f, ""
+		if i := strings.LastIndexByte(f, ' '); i >= 0 {
+			switch d := strings.ToUpper(f[i+1:]); d {
+			case ascStmt, descStmt:
+				name, dir = strings.TrimSpace(f[:i]), " "+d
+			}
+		}
+
+		t, c, err := b.field(name)
+		if err != nil {
+			b.err = err
+			return b
+		}
+		b.orderBy = append(b.orderBy, qualifiedColumn(t, c)+dir)
+	}
+	return b
+}
+
+// OrderByCols adds columns to the ORDER BY clause, in descending order for
+// columns given with ColRef.Desc.
+func (b *SelectBuilder) OrderByCols(cols ...ColRef) *SelectBuilder {
+	for _, c := range cols {
+		if b.err != nil {
+			return b
+		}
+		sql, err := c.resolve(b)
+		if err != nil {
+			b.err = err
+			return b
+		}
+		if c.desc {
+			sql += " " + descStmt
+		}
+		b.orderBy = append(b.orderBy, sql)
+	}
+	return b
+}
+
+// field resolves a field named Field or Model.Field to its column.
+func (b *SelectBuilder) field(name string) (*table, column, error) {
+	if b.from == nil {
+		return nil, column{}, b.err
+	}
+	t, field := b.from.t, name
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		model := name[:i]
+		t, field = nil, name[i+1:]
+		for _, qt := range b.tables() {
+			if qt.t.model.Name() != model {
+				continue
+			}
+			// Models of the same name may be declared in different packages
+			if t != nil {
+				return nil, column{}, fmt.Errorf(`Select model "%s" of field "%s" is ambiguous, both "%s" and "%s" are in the query`,
+					model, name, typeName(t.model), typeName(qt.t.model))
+			}
+			t = qt.t
+		}
+		if t == nil {
+			return nil, column{}, fmt.Errorf(`Select has no model "%s" for field "%s"`, model, name)
+		}
+	}
+
+	for _, c := range t.cols {
+		if c.fieldName == field {
+			return t, c, nil
+		}
+	}
+	return nil, column{}, fmt.Errorf(`Select has no field "%s" in model "%s"`, field, typeName(t.model))
+}
+
+// Limit limits the number of rows of the result.
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	if n < 0 && b.err == nil {
+		b.err = fmt.Errorf("Select requires a row count of at least 0 for LIMIT, %d given", n)
+	}
+	b.limit = limitStmt + " " + strconv.Itoa(n)
+	return b
+}
+
+// LimitOffset limits the number of rows of the result to n, skipping the
+// first offset rows.
+func (b *SelectBuilder) LimitOffset(n, offset int) *SelectBuilder {
+	if offset < 0 && b.err == nil {
+		b.err = fmt.Errorf("Select requires an offset of at least 0 for LIMIT, %d given", offset)
+	}
+	b.Limit(n)
+	b.limit += " " + offsetStmt + " " + strconv.Itoa(offset)
+	return b
+}
+
+// SQL returns the composed SELECT statement and its arguments in placeholder
+// order, or the first problem found composing it.
+func (b *SelectBuilder) SQL() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+
+	var sb strings.Builder
+	var args []interface{}
+	sb.WriteString(selectStmt + " ")
+	for _, m := range b.modifiers {
+		sb.WriteString(keywordText[m] + " ")
+	}
+	cols := make([]string, len(b.from.t.cols))
+	for i, c := range b.from.t.cols {
+		cols[i] = qualifiedColumn(b.from.t, c)
+	}
+	sb.WriteString(strings.Join(cols, ", "))
+
+	sb.WriteString(" " + fromStmt + " " + b.from.String())
+	for _, j := range b.joins {
+		sb.WriteString(" " + keywordText[j.join] + " " + j.String())
+		if len(j.on) > 0 {
+			on, onArgs, err := And(j.on...).build(b)
+			if err != nil {
+				return "", nil, err
+			}
+			sb.WriteString(" " + onStmt + " " + on)
+			args = append(args, onArgs...)
+		}
+	}
+
+	if len(b.where) > 0 {
+		where, whereArgs, err := And(b.where...).build(b)
+		if err != nil {
+			return "", nil, err
+		}
+		sb.WriteString(" " + whereStmt + " " + where)
+		args = append(args, whereArgs...)
+	}
+	if len(b.orderBy) > 0 {
+		sb.WriteString(" " + orderByStmt + " " + strings.Join(b.orderBy, ", "))
+	}
+	if b.limit != "" {
+		sb.WriteString(" " + b.limit)
+	}
+	return sb.String(), args, nil
+}
+
+// tables returns the tables of the query.
+func (b *SelectBuilder) tables() []*builderTable {
+	tables := []*builderTable{b.from}
+	for _, j := range b.joins {
+		tables = append(tables, &j.builderTable)
+	}
+	return tables
+}
+
+// table returns the table of the query mapped to t, or nil.
+func (b *SelectBuilder) table(t *table) *builderTable {
+	for _, qt := range b.tables() {
+		if qt.t == t {
+			return qt
+		}
+	}
+	return nil
+}
+
+// outerJoined reports whether the rows of table t of the query may be NULL
+// extended by an outer join: t is LEFT joined, or a table joined after t is
+// RIGHT joined.
+func (b *SelectBuilder) outerJoined(t *table) bool {
+	tables := b.tables()
+	for i, j := range b.joins {
+		switch j.join {
+		case leftJoinToken, naturalLeftJoinToken:
+			if j.t == t {
+				return true
+			}
+		case rightJoinToken, naturalRightJoinToken:
+			// tables[i] is the last table joined before j
+			for _, qt := range tables[:i+1] {
+				if qt.t == t {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// String returns the SQL of the table reference.
+func (qt *builderTable) String() string {
+	s := qt.t.qualifiedName()
+	for _, h := range qt.hints {
+		s += " " + h
+	}
+	return s
+}
+
+// isNaturalJoin reports whether t is a natural join, which takes no condition.
+func isNaturalJoin(t token) bool {
+	switch t {
+	case naturalJoinToken, naturalLeftJoinToken, naturalLeftOuterJoinToken,
+		naturalRightJoinToken, naturalRightOuterJoinToken:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package rdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+type builderComment struct {
+	ID     int64  `db:"database=blog,table=comments,col=id,pk,ai"`
+	PostID int64  `db:"col=post_id"`
+	Post   int64  `db:"col=post,fkmap=post_id.relPost.ID"`
+	Body   string `db:"col=body"`
+}
+
+// newBuilderRegistry returns a registry of the blog test models.
+func newBuilderRegistry(t *testing.T) *Registry {
+	t.Helper()
+	reg := NewRegistry()
+	for _, m := range []interface{}{relAuthor{}, relPost{}, builderComment{}, crudUser{}} {
+		if e := reg.Register(m); e != nil {
+			t.Fatalf("Not expecting error registering %T but got: %s", m, e.Error())
+		}
+	}
+	return reg
+}
+
+func TestSelectBuilderSQL(t *testing.T) {
+	reg := newBuilderRegistry(t)
+	tests := []struct {
+		b     *SelectBuilder
+		query string
+		args  []interface{}
+	}{
+		{reg.Select(&relAuthor{}),
+			"SELECT `blog`.`authors`.`id`, `blog`.`authors`.`parent_id`, `blog`.`authors`.`parent` FROM `blog`.`authors`", nil},
+		{reg.Select(builderComment{}).Distinct().CalcFoundRows().Distinct().
+			Join(&relPost{}).ForceIndex("PRIMARY").
+			LeftJoin(&relAuthor{}).
+			Where(Raw("`blog`.`authors`.`id` = ?", 7), Raw("`blog`.`comments`.`body` LIKE ? OR ? IS NULL", "%x%", nil)).
+			OrderBy("Post", "relAuthor.ID desc").
+			LimitOffset(10, 20),
+			"SELECT DISTINCT SQL_CALC_FOUND_ROWS `blog`.`comments`.`id`, `blog`.`comments`.`post_id`, `blog`.`comments`.`post`, `blog`.`comments`.`body` " +
+				"FROM `blog`.`comments` " +
+				"JOIN `blog`.`posts` FORCE INDEX (`PRIMARY`) ON `blog`.`comments`.`post_id` = `blog`.`posts`.`id` " +
+				"LEFT JOIN `blog`.`authors` ON `blog`.`posts`.`author_id` = `blog`.`authors`.`id` " +
+				"WHERE (`blog`.`authors`.`id` = ?) AND (`blog`.`comments`.`body` LIKE ? OR ? IS NULL) " +
+				"ORDER BY `blog`.`comments`.`post`, `blog`.`authors`.`id` DESC LIMIT 10 OFFSET 20",
+			[]interface{}{7, "%x%", nil}},
+		{reg.Select(&relPost{}).HighPriority().StraightJoin(&relAuthor{}, Raw("1 = ?", 1)).UseIndex().
+			CrossJoin(&crudUser{}).Limit(1),
+			"SELECT HIGH_PRIORITY `blog`.`posts`.`id`, `blog`.`posts`.`author_id`, `blog`.`posts`.`author` FROM `blog`.`posts` " +
+				"STRAIGHT_JOIN `blog`.`authors` USE INDEX () ON 1 = ? CROSS JOIN `app`.`users` LIMIT 1",
+			[]interface{}{1}},
+	}
+
+	for _, tt := range tests {
+		query, args, err := tt.b.SQL()
+		if err != nil {
+			t.Errorf("Not expecting error building %q but got: %s", tt.query, err.Error())
+			continue
+		}
+		if query != tt.query {
+			t.Errorf("Expected:\n%s\nGot:\n%s", tt.query, query)
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("Expected args %v, got %v", tt.args, args)
+		}
+		if _, err := Parse(query); err != nil {
+			t.Errorf("Not expecting error parsing built query but got: %s", err.Error())
+		}
+	}
+}
+
+func TestSelectBuilderErrors(t *testing.T) {
+	reg := newBuilderRegistry(t)
+	type unregistered struct{}
+	tests := []*SelectBuilder{
+		reg.Select(unregistered{}),
+		reg.Select(&relPost{}).Join(&unregistered{}),
+		reg.Select(&relPost{}).Join(&relPost{}),
+		reg.Select(&relPost{}).Join(&crudUser{}),
+		reg.Select(&relPost{}).IgnoreIndex(),
+		reg.Select(&relPost{}).OrderBy("Missing"),
+		reg.Select(&relPost{}).OrderBy("relAuthor.ID"),
+		reg.Select(&relPost{}).Where(Raw("`id` = ?")),
+		reg.Select(&relPost{}).Where(Raw("`id` = 'x", 1)),
+		reg.Select(&relPost{}).Limit(-1),
+		reg.Select(&relPost{}).LimitOffset(-1, 0),
+		reg.Select(&relPost{}).LimitOffset(1, -1),
+	}
+	for i, b := range tests {
+		if _, _, err := b.SQL(); err == nil {
+			t.Errorf("Expected error building query %d", i)
+		}
+	}
+
+	// Several foreign keys relating the joined model are ambiguous
+	type builderReply struct {
+		ID       int64 `db:"database=blog,table=replies,col=id,pk"`
+		PostID   int64 `db:"col=post_id"`
+		Post     int64 `db:"col=post,fkmap=post_id.relPost.ID"`
+		QuotedID int64 `db:"col=quoted_id"`
+		Quoted   int64 `db:"col=quoted,fkmap=quoted_id.relPost.ID"`
+	}
+	if e := reg.Register(builderReply{}); e != nil {
+		t.Fatalf("Not expecting error registering a reply but got: %s", e.Error())
+	}
+	if _, _, err := reg.Select(&relPost{}).Join(&builderReply{}).SQL(); err == nil {
+		t.Errorf("Expected error joining on ambiguous foreign keys")
+	}
+}
+
+// builderPostTag and builderCommentTag return models of the same name, as
+// if declared in different packages.
+func builderPostTag() interface{} {
+	type builderTag struct {
+		ID     int64 `db:"database=blog,table=post_tags,col=id,pk"`
+		PostID int64 `db:"col=post_id"`
+		Post   int64 `db:"col=post,fkmap=post_id.relPost.ID"`
+	}
+	return builderTag{}
+}
+
+func builderCommentTag() interface{} {
+	type builderTag struct {
+		ID        int64 `db:"database=blog,table=comment_tags,col=id,pk"`
+		CommentID int64 `db:"col=comment_id"`
+		Comment   int64 `db:"col=comment,fkmap=comment_id.builderComment.ID"`
+	}
+	return builderTag{}
+}
+
+func TestSelectBuilderAmbiguousModelName(t *testing.T) {
+	reg := newBuilderRegistry(t)
+	postTag, commentTag := builderPostTag(), builderCommentTag()
+	for _, m := range []interface{}{postTag, commentTag} {
+		if e := reg.Register(m); e != nil {
+			t.Fatalf("Not expecting error registering %T but got: %s", m, e.Error())
+		}
+	}
+
+	b := reg.Select(&relPost{}).Join(postTag)
+	if _, _, err := b.OrderBy("builderTag.ID").SQL(); err != nil {
+		t.Errorf("Not expecting error ordering by the single builderTag but got: %s", err.Error())
+	}
+
+	_, _, err := reg.Select(&relPost{}).Join(postTag).Join(&builderComment{}).Join(commentTag).
+		OrderBy("builderTag.ID").SQL()
+	m := `Select model "builderTag" of field "builderTag.ID" is ambiguous, both "` +
+		typeName(reflect.TypeOf(postTag)) + `" and "` + typeName(reflect.TypeOf(commentTag)) + `" are in the query`
+	if err == nil || err.Error() != m {
+		t.Errorf("Expected:\n'%s'\nGot:\n'%v'", m, err)
+	}
+}
+
+func TestSelectBuilderRunsWithSelect(t *testing.T) {
+	db, srv := newCrudRdb(t, fakeResponse{
+		columns: []string{"id", "email", "name"},
+		rows:    [][]driver.Value{{int64(1), "a@example.com", nil}},
+	})
+	query, args, err := db.Registry.Select(&crudUser{}).Join(&crudMembership{},
+		Raw("`app`.`memberships`.`user_id` = `app`.`users`.`id`")).
+		Where(Raw("`app`.`memberships`.`group_id` = ?", 3)).SQL()
+	if err != nil {
+		t.Fatalf("Not expecting error building query but got: %s", err.Error())
+	}
+
+	var users []crudUser
+	if err := db.Select(context.Background(), &users, query, args...); err != nil {
+		t.Fatalf("Not expecting error on Select but got: %s", err.Error())
+	}
+	if len(users) != 1 || users[0].Email != "a@example.com" {
+		t.Errorf("Expected a single user, got %+v", users)
+	}
+	if c := srv.call(0); c.query != query || !reflect.DeepEqual(c.args, []driver.Value{int64(3)}) {
+		t.Errorf("Expected %s with args [3], got %s with %v", query, c.query, c.args)
+	}
+}
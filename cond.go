@@ -0,0 +1,381 @@
+package rdb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ColRef is a typed reference to the column of a field of a registered model,
+// see Col. Conditions built on a ColRef are checked against the kind and
+// nullability of the column, and a query referencing a renamed or removed
+// field fails to compile rather than at runtime.
+type ColRef struct {
+	t    *table
+	c    column
+	desc bool
+	err  error
+}
+
+// Col returns a reference to the column of a field of a model registered with
+// the default Registry, see Registry.Col.
+func Col(model, field interface{}) ColRef {
+	return defaultRegistry.Col(model, field)
+}
+
+// Col returns a reference to the column of a field of a registered model.
+// model is a pointer to a model struct and field a pointer to one of its
+// mapped fields, as in:
+//
+//	var u User
+//	email := reg.Col(&u, &u.Email)
+//
+// A problem resolving the column is reported when the query using it is
+// built.
+func (reg *Registry) Col(model, field interface{}) ColRef {
+	mv := reflect.ValueOf(model)
+	if mv.Kind() != reflect.Ptr || mv.IsNil() || mv.Elem().Kind() != reflect.Struct {
+		return ColRef{err: fmt.Errorf("Col requires a pointer to a model struct, %T given", model)}
+	}
+	t, err := reg.table(model)
+	if err != nil {
+		return ColRef{err: err}
+	}
+
+	fv := reflect.ValueOf(field)
+	if fv.Kind() != reflect.Ptr || fv.IsNil() {
+		return ColRef{err: fmt.Errorf("Col requires a pointer to a field of the model, %T given", field)}
+	}
+	for _, c := range t.cols {
+		f, err := mv.Elem().FieldByIndexErr(c.index)
+		if err != nil {
+			continue
+		}
+		// A struct and its first field share an address, so types must match
+		if f.Addr().Pointer() == fv.Pointer() && f.Type() == fv.Elem().Type() {
+			return ColRef{t: t, c: c}
+		}
+	}
+	return ColRef{err: fmt.Errorf(`Col field %T is not a mapped field of model "%s"`, field, typeName(t.model))}
+}
+
+// Desc returns the reference ordering by the column in descending order, see
+// SelectBuilder.OrderByCols.
+func (r ColRef) Desc() ColRef {
+	r.desc = true
+	return r
+}
+
+// String returns the database qualified column name.
+func (r ColRef) String() string {
+	if r.t == nil {
+		return ""
+	}
+	return qualifiedColumn(r.t, r.c)
+}
+
+// valid returns the problem resolving the column, if any.
+func (r ColRef) valid() error {
+	if r.err == nil && r.t == nil {
+		return fmt.Errorf("ColRef does not reference a column, see Col")
+	}
+	return r.err
+}
+
+// kind returns the kind of the values of the column.
+func (r ColRef) kind() reflect.Kind {
+	return valueKind(r.t.model.FieldByIndex(r.c.index).Type)
+}
+
+// name returns the name of the column for error messages, Model.Field.
+func (r ColRef) name() string {
+	return r.t.model.Name() + "." + r.c.fieldName
+}
+
+// resolve returns the SQL of the column, checking it is a column of a table
+// of the query built by b.
+func (r ColRef) resolve(b *SelectBuilder) (string, error) {
+	if err := r.valid(); err != nil {
+		return "", err
+	}
+	if b.table(r.t) == nil {
+		return "", fmt.Errorf(`Column "%s" of model "%s" is not in the query`, r.c.colName, typeName(r.t.model))
+	}
+	return qualifiedColumn(r.t, r.c), nil
+}
+
+// check returns a problem if v can not be compared to the column: a nil value
+// or a value of a kind which can not be stored in the column. Another column
+// must be of a compatible kind, values implementing driver.Valuer convert
+// themselves and are not checked.
+func (r ColRef) check(op string, v interface{}) error {
+	if err := r.valid(); err != nil {
+		return err
+	}
+	if v == nil {
+		return fmt.Errorf(`%s on column "%s" requires a value, use IsNull to match NULL`, op, r.name())
+	}
+
+	var k reflect.Kind
+	switch v := v.(type) {
+	case ColRef:
+		if err := v.valid(); err != nil {
+			return err
+		}
+		k = v.kind()
+	case driver.Valuer:
+		return nil
+	default:
+		k = valueKind(reflect.TypeOf(v))
+	}
+	if valueClass(k) != valueClass(r.kind()) {
+		return fmt.Errorf(`%s on column "%s" of kind %s is given a value of kind %s`, op, r.name(), r.kind(), k)
+	}
+	return nil
+}
+
+// valueClass groups the kinds of values which may be compared, all numbers
+// being comparable.
+func valueClass(k reflect.Kind) reflect.Kind {
+	switch kindClass(k) {
+	case reflect.Int, reflect.Uint, reflect.Float64:
+		return reflect.Float64
+	}
+	return kindClass(k)
+}
+
+// operand returns the SQL of a value compared to a column: the column of a
+// ColRef or a placeholder and its argument.
+func operand(b *SelectBuilder, v interface{}) (string, []interface{}, error) {
+	if r, ok := v.(ColRef); ok {
+		sql, err := r.resolve(b)
+		return sql, nil, err
+	}
+	return "?", []interface{}{v}, nil
+}
+
+// compareCond compares a column to a value or another column.
+type compareCond struct {
+	col ColRef
+	op  string
+	v   interface{}
+	err error
+}
+
+// compare returns the condition comparing col to v with op.
+func compare(col ColRef, op string, v interface{}) Cond {
+	return compareCond{col: col, op: op, v: v, err: col.check(op, v)}
+}
+
+// Eq returns the condition col = v, v is a value or another ColRef.
+func Eq(col ColRef, v interface{}) Cond {
+	return compare(col, "=", v)
+}
+
+// Ne returns the condition col <> v, v is a value or another ColRef.
+func Ne(col ColRef, v interface{}) Cond {
+	return compare(col, "<>", v)
+}
+
+// Lt returns the condition col < v, v is a value or another ColRef.
+func Lt(col ColRef, v interface{}) Cond {
+	return compare(col, "<", v)
+}
+
+// Le returns the condition col <= v, v is a value or another ColRef.
+func Le(col ColRef, v interface{}) Cond {
+	return compare(col, "<=", v)
+}
+
+// Gt returns the condition col > v, v is a value or another ColRef.
+func Gt(col ColRef, v interface{}) Cond {
+	return compare(col, ">", v)
+}
+
+// Ge returns the condition col >= v, v is a value or another ColRef.
+func Ge(col ColRef, v interface{}) Cond {
+	return compare(col, ">=", v)
+}
+
+func (c compareCond) build(b *SelectBuilder) (string, []interface{}, error) {
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	col, err := c.col.resolve(b)
+	if err != nil {
+		return "", nil, err
+	}
+	v, args, err := operand(b, c.v)
+	if err != nil {
+		return "", nil, err
+	}
+	return col + " " + c.op + " " + v, args, nil
+}
+
+// inCond matches a column against a list of values.
+type inCond struct {
+	col    ColRef
+	values []interface{}
+	err    error
+}
+
+// In returns the condition col IN (values...). At least one value is
+// required.
+func In(col ColRef, values ...interface{}) Cond {
+	c := inCond{col: col, values: values}
+	if len(values) == 0 && col.valid() == nil {
+		c.err = fmt.Errorf(`IN on column "%s" requires values`, col.name())
+	}
+	for _, v := range values {
+		if c.err == nil {
+			c.err = col.check(inStmt, v)
+		}
+	}
+	return c
+}
+
+func (c inCond) build(b *SelectBuilder) (string, []interface{}, error) {
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	col, err := c.col.resolve(b)
+	if err != nil {
+		return "", nil, err
+	}
+	var args []interface{}
+	list := make([]string, len(c.values))
+	for i, v := range c.values {
+		sql, vArgs, err := operand(b, v)
+		if err != nil {
+			return "", nil, err
+		}
+		list[i] = sql
+		args = append(args, vArgs...)
+	}
+	return col + " " + inStmt + " (" + strings.Join(list, ", ") + ")", args, nil
+}
+
+// betweenCond matches a column within a range.
+type betweenCond struct {
+	col    ColRef
+	lo, hi interface{}
+	err    error
+}
+
+// Between returns the condition col BETWEEN lo AND hi.
+func Between(col ColRef, lo, hi interface{}) Cond {
+	err := col.check(betweenStmt, lo)
+	if err == nil {
+		err = col.check(betweenStmt, hi)
+	}
+	return betweenCond{col: col, lo: lo, hi: hi, err: err}
+}
+
+func (c betweenCond) build(b *SelectBuilder) (string, []interface{}, error) {
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	col, err := c.col.resolve(b)
+	if err != nil {
+		return "", nil, err
+	}
+	lo, args, err := operand(b, c.lo)
+	if err != nil {
+		return "", nil, err
+	}
+	hi, hiArgs, err := operand(b, c.hi)
+	if err != nil {
+		return "", nil, err
+	}
+	return col + " " + betweenStmt + " " + lo + " " + andStmt + " " + hi, append(args, hiArgs...), nil
+}
+
+// Like returns the condition col LIKE pattern, col must be a string column.
+func Like(col ColRef, pattern string) Cond {
+	c := compareCond{col: col, op: likeStmt, v: pattern, err: col.valid()}
+	if c.err == nil && col.kind() != reflect.String {
+		c.err = fmt.Errorf(`LIKE on column "%s" requires a string column, it is of kind %s`, col.name(), col.kind())
+	}
+	return c
+}
+
+// isNullCond matches NULL values of a column.
+type isNullCond struct {
+	col ColRef
+	err error
+}
+
+// IsNull returns the condition col IS NULL. col must be nullable or a column
+// of an outer joined table, whose unmatched rows are NULL.
+func IsNull(col ColRef) Cond {
+	return isNullCond{col: col, err: col.valid()}
+}
+
+func (c isNullCond) build(b *SelectBuilder) (string, []interface{}, error) {
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	col, err := c.col.resolve(b)
+	if err != nil {
+		return "", nil, err
+	}
+	if !c.col.c.null && !b.outerJoined(c.col.t) {
+		return "", nil, fmt.Errorf(`IS NULL on column "%s" which is not nullable`, c.col.name())
+	}
+	return col + " " + isStmt + " " + nullStmt, nil, nil
+}
+
+// logicalCond combines conditions with AND or OR.
+type logicalCond struct {
+	op    string
+	conds []Cond
+}
+
+// And returns the condition holding when every one of conds holds.
+func And(conds ...Cond) Cond {
+	return logicalCond{op: andStmt, conds: conds}
+}
+
+// Or returns the condition holding when any one of conds holds.
+func Or(conds ...Cond) Cond {
+	return logicalCond{op: orStmt, conds: conds}
+}
+
+func (c logicalCond) build(b *SelectBuilder) (string, []interface{}, error) {
+	if len(c.conds) == 0 {
+		return "", nil, fmt.Errorf("%s requires conditions", c.op)
+	}
+	var args []interface{}
+	sqls := make([]string, len(c.conds))
+	for i, cond := range c.conds {
+		sql, condArgs, err := cond.build(b)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(c.conds) > 1 {
+			sql = "(" + sql + ")"
+		}
+		sqls[i] = sql
+		args = append(args, condArgs...)
+	}
+	return strings.Join(sqls, " "+c.op+" "), args, nil
+}
+
+// notCond negates a condition.
+type notCond struct {
+	cond Cond
+}
+
+// Not returns the condition holding when cond does not hold.
+func Not(cond Cond) Cond {
+	return notCond{cond: cond}
+}
+
+func (c notCond) build(b *SelectBuilder) (string, []interface{}, error) {
+	sql, args, err := c.cond.build(b)
+	if err != nil {
+		return "", nil, err
+	}
+	return notStmt + " (" + sql + ")", args, nil
+}
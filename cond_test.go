@@ -0,0 +1,143 @@
+package rdb
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColResolvesFields(t *testing.T) {
+	reg := newBuilderRegistry(t)
+	var p relPost
+	if c := reg.Col(&p, &p.AuthorID); c.valid() != nil || c.String() != "`blog`.`posts`.`author_id`" {
+		t.Errorf("Expected column `blog`.`posts`.`author_id`, got %q (%v)", c.String(), c.valid())
+	}
+
+	var u crudUser
+	var other relPost
+	tests := []struct {
+		c   ColRef
+		msg string
+	}{
+		{reg.Col(p, &p.ID), "Col requires a pointer to a model struct, rdb.relPost given"},
+		{reg.Col(&p, p.ID), "Col requires a pointer to a field of the model, int64 given"},
+		{reg.Col(&p, &other.ID), `Col field *int64 is not a mapped field of model "` + typeName(reflect.TypeOf(p)) + `"`},
+		{reg.Col(&p, &p.AuthorID.Int64), `Col field *int64 is not a mapped field of model "` + typeName(reflect.TypeOf(p)) + `"`},
+		{NewRegistry().Col(&u, &u.Email), ErrNotRegistered.Error()},
+		{ColRef{}, "ColRef does not reference a column, see Col"},
+	}
+	for _, tt := range tests {
+		if err := tt.c.valid(); err == nil || !strings.HasPrefix(err.Error(), tt.msg) {
+			t.Errorf("Expected error %q, got %v", tt.msg, err)
+		}
+	}
+}
+
+func TestConditionsBuildSQL(t *testing.T) {
+	reg := newBuilderRegistry(t)
+	var c builderComment
+	var p relPost
+	var a relAuthor
+	body, postID := reg.Col(&c, &c.Body), reg.Col(&c, &c.PostID)
+	id, authorID, parentID := reg.Col(&p, &p.ID), reg.Col(&p, &p.AuthorID), reg.Col(&a, &a.ParentID)
+
+	query, args, err := reg.Select(&c).Join(&p, Eq(postID, id)).LeftJoin(&a).
+		Where(
+			Or(Like(body, "%rdb%"), Not(In(postID, 1, uint8(2), 3.0))),
+			Between(authorID, sql.NullInt64{Int64: 1, Valid: true}, int64(9)),
+			IsNull(parentID),
+			Ne(id, int32(4)), Lt(id, 5), Le(id, 6), Gt(id, 7), Ge(id, postID),
+		).
+		OrderByCols(id.Desc(), body).SQL()
+	if err != nil {
+		t.Fatalf("Not expecting error building query but got: %s", err.Error())
+	}
+
+	where := "WHERE ((`blog`.`comments`.`body` LIKE ?) OR (NOT (`blog`.`comments`.`post_id` IN (?, ?, ?)))) AND " +
+		"(`blog`.`posts`.`author_id` BETWEEN ? AND ?) AND (`blog`.`authors`.`parent_id` IS NULL) AND " +
+		"(`blog`.`posts`.`id` <> ?) AND (`blog`.`posts`.`id` < ?) AND (`blog`.`posts`.`id` <= ?) AND " +
+		"(`blog`.`posts`.`id` > ?) AND (`blog`.`posts`.`id` >= `blog`.`comments`.`post_id`) " +
+		"ORDER BY `blog`.`posts`.`id` DESC, `blog`.`comments`.`body`"
+	if !strings.Contains(query, " JOIN `blog`.`posts` ON `blog`.`comments`.`post_id` = `blog`.`posts`.`id` ") ||
+		!strings.HasSuffix(query, where) {
+		t.Errorf("Expected query ending with:\n%s\nGot:\n%s", where, query)
+	}
+
+	expected := []interface{}{"%rdb%", 1, uint8(2), 3.0, sql.NullInt64{Int64: 1, Valid: true}, int64(9), int32(4), 5, 6, 7}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected args %v, got %v", expected, args)
+	}
+	if _, err := Parse(query); err != nil {
+		t.Errorf("Not expecting error parsing built query but got: %s", err.Error())
+	}
+}
+
+func TestConditionsAreValidated(t *testing.T) {
+	reg := newBuilderRegistry(t)
+	var c builderComment
+	var p relPost
+	var u crudUser
+	body, postID := reg.Col(&c, &c.Body), reg.Col(&c, &c.PostID)
+	email := reg.Col(&u, &u.Email)
+
+	tests := []struct {
+		cond Cond
+		msg  string
+	}{
+		{Eq(postID, "1"), `= on column "builderComment.PostID" of kind int64 is given a value of kind string`},
+		{Eq(body, nil), `= on column "builderComment.Body" requires a value, use IsNull to match NULL`},
+		{Gt(body, postID), `> on column "builderComment.Body" of kind string is given a value of kind int64`},
+		{In(postID), `IN on column "builderComment.PostID" requires values`},
+		{In(postID, 1, true), `IN on column "builderComment.PostID" of kind int64 is given a value of kind bool`},
+		{Between(body, "a", 1), `BETWEEN on column "builderComment.Body" of kind string is given a value of kind int`},
+		{Like(postID, "1%"), `LIKE on column "builderComment.PostID" requires a string column, it is of kind int64`},
+		{IsNull(body), `IS NULL on column "builderComment.Body" which is not nullable`},
+		{Not(Eq(ColRef{}, 1)), "ColRef does not reference a column, see Col"},
+		{Or(), "OR requires conditions"},
+		{Eq(email, "a@example.com"), `Column "email" of model "` + typeName(reflect.TypeOf(u)) + `" is not in the query`},
+		{Eq(body, email), `Column "email" of model "` + typeName(reflect.TypeOf(u)) + `" is not in the query`},
+	}
+	for _, tt := range tests {
+		_, _, err := reg.Select(&c).Join(&p).Where(tt.cond).SQL()
+		if err == nil || err.Error() != tt.msg {
+			t.Errorf("Expected error %q, got %v", tt.msg, err)
+		}
+	}
+
+	if _, _, err := reg.Select(&c).OrderByCols(email).SQL(); err == nil {
+		t.Errorf("Expected error ordering by a column which is not in the query")
+	}
+}
+
+func TestIsNullOnOuterJoinedTables(t *testing.T) {
+	reg := newBuilderRegistry(t)
+	var c builderComment
+	var p relPost
+	postID, body, id := reg.Col(&c, &c.PostID), reg.Col(&c, &c.Body), reg.Col(&p, &p.ID)
+
+	// Posts without comments
+	query, _, err := reg.Select(&p).LeftJoin(&c).Where(IsNull(postID)).SQL()
+	if err != nil {
+		t.Fatalf("Not expecting error building an anti-join but got: %s", err.Error())
+	}
+	if where := "WHERE `blog`.`comments`.`post_id` IS NULL"; !strings.HasSuffix(query, where) {
+		t.Errorf("Expected query ending with %s, got %s", where, query)
+	}
+
+	tests := []struct {
+		b   *SelectBuilder
+		err bool
+	}{
+		{reg.Select(&p).LeftJoin(&c).Where(Not(IsNull(body))), false},
+		{reg.Select(&c).RightJoin(&p).Where(IsNull(body)), false},
+		{reg.Select(&c).RightJoin(&p).Where(IsNull(id)), true},
+		{reg.Select(&c).LeftJoin(&p).Where(IsNull(postID)), true},
+		{reg.Select(&p).Join(&c).Where(IsNull(postID)), true},
+	}
+	for i, tt := range tests {
+		if _, _, err := tt.b.SQL(); (err != nil) != tt.err {
+			t.Errorf("Expected error %v building query %d, got %v", tt.err, i, err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrNotFound is matched by errors.Is on a *NotFoundError.
+var ErrNotFound = errors.New("Record not found")
+
+// NotFoundError is returned when no row matches the primary key of a model.
+// It matches both ErrNotFound and sql.ErrNoRows with errors.Is.
+type NotFoundError struct {
+	Model string        // Name of the model looked up
+	Table Table         // Table the model maps to
+	Keys  []interface{} // Primary key values in key order
+}
+
+// Error returns a description of the missing record.
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("No %s record found in %s for primary key %v", e.Model, e.Table, e.Keys)
+}
+
+// Is reports whether target is ErrNotFound or sql.ErrNoRows.
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound || target == sql.ErrNoRows
+}
+
+// querier runs statements, it is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Insert inserts the registered model pointed to by model into its table. All
+// mapped columns except the auto-increment column are inserted, the ID
+// generated by the database is written back to the auto-increment field.
+func (r *Rdb) Insert(ctx context.Context, model interface{}) error {
+	return insert(ctx, r.Db, r.registry(), model)
+}
+
+func insert(ctx context.Context, q querier, reg *Registry, model interface{}) error {
+	t, v, err := reg.modelValue("Insert", model)
+	if err != nil {
+		return err
+	}
+
+	query, args := t.insertQuery(v)
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	ai, ok := t.autoIncrement()
+	if !ok {
+		return nil
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	return setID(v.FieldByIndex(ai.index), id)
+}
+
+// Get loads the row of a registered model by primary key into the model
+// pointed to by model. The key values are given in key order, see
+// Model.PrimaryKey, or taken from the key fields of model when none are
+// given. A *NotFoundError is returned when no row matches the key.
+func (r *Rdb) Get(ctx context.Context, model interface{}, keys ...interface{}) error {
+	return get(ctx, r.Db, r.registry(), model, keys)
+}
+
+// Update updates every column but the primary key columns of the row of the
+// registered model pointed to by model, matching the row by the key fields of
+// the model. It returns the number of rows affected which, as reported by
+// MySQL, is 0 both when no row matches the key and when no value changed.
+func (r *Rdb) Update(ctx context.Context, model interface{}) (int64, error) {
+	return update(ctx, r.Db, r.registry(), model)
+}
+
+// Delete deletes the row of the registered model pointed to by model,
+// matching the row by the key fields of the model. It returns the number of
+// rows affected and a *NotFoundError when no row matches the key.
+func (r *Rdb) Delete(ctx context.Context, model interface{}) (int64, error) {
+	return remove(ctx, r.Db, r.registry(), model)
+}
+
+func get(ctx context.Context, q querier, reg *Registry, model interface{}, keys []interface{}) error {
+	t, v, err := reg.keyedValue("Get", model)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		keys = t.keyArgs(v)
+	} else if len(keys) != len(t.pk) {
+		return fmt.Errorf(`Get requires %d primary key values for model "%s", %d given`,
+			len(t.pk), typeName(t.model), len(keys))
+	}
+
+	targets, finish := fieldTargets(v, t.cols)
+	err = q.QueryRowContext(ctx, t.getQuery(), keys...).Scan(targets...)
+	if err == sql.ErrNoRows {
+		return t.notFound(keys)
+	}
+	if err != nil {
+		return err
+	}
+
+	finish()
+	return nil
+}
+
+func update(ctx context.Context, q querier, reg *Registry, model interface{}) (int64, error) {
+	t, v, err := reg.keyedValue("Update", model)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(t.pk) == len(t.cols) {
+		return 0, fmt.Errorf(`Update requires model "%s" to map columns outside of its primary key`, typeName(t.model))
+	}
+
+	query, args := t.updateQuery(v)
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func remove(ctx context.Context, q querier, reg *Registry, model interface{}) (int64, error) {
+	t, v, err := reg.keyedValue("Delete", model)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := t.keyArgs(v)
+	res, err := q.ExecContext(ctx, t.deleteQuery(), keys...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, t.notFound(keys)
+	}
+	return n, nil
+}
+
+// notFound returns the error reporting that no row of the table matches keys.
+func (t *table) notFound(keys []interface{}) error {
+	return &NotFoundError{
+		Model: typeName(t.model),
+		Table: Table{db: t.dbName, name: t.name},
+		Keys:  keys,
+	}
+}
+
+// keyedValue is modelValue for operations matching rows by primary key, which
+// require the model to declare one.
+func (reg *Registry) keyedValue(op string, model interface{}) (*table, reflect.Value, error) {
+	t, v, err := reg.modelValue(op, model)
+	if err != nil {
+		return nil, v, err
+	}
+
+	if len(t.pk) == 0 {
+		return nil, v, fmt.Errorf(`%s requires model "%s" to declare a primary key`, op, typeName(t.model))
+	}
+	return t, v, nil
+}
+
+// modelValue returns the table definition and struct value of a pointer to a
+// registered model, op names the operation requiring it for error messages.
+func (reg *Registry) modelValue(op string, model interface{}) (*table, reflect.Value, error) {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, reflect.Value{}, fmt.Errorf("%s requires a pointer to a model struct, %T given", op, model)
+	}
+
+	t, err := reg.table(model)
+	if err != nil {
+		return nil, reflect.Value{}, err
+	}
+	return t, v.Elem(), nil
+}
+
+// setID sets an auto-increment field to a generated ID, allocating the
+// value of pointer fields.
+func setID(f reflect.Value, id int64) error {
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+		f = f.Elem()
+	}
+
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f.OverflowInt(id) {
+			return fmt.Errorf("Generated ID %d overflows auto-increment field of type %s", id, f.Type())
+		}
+		f.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if id < 0 || f.OverflowUint(uint64(id)) {
+			return fmt.Errorf("Generated ID %d overflows auto-increment field of type %s", id, f.Type())
+		}
+		f.SetUint(uint64(id))
+	default:
+		return fmt.Errorf("Cannot set generated ID on auto-increment field of type %s", f.Type())
+	}
+	return nil
+}
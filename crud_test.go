@@ -0,0 +1,239 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type crudUser struct {
+	ID    uint32 `db:"database=app,table=users,col=id,pk,ai"`
+	Email string `db:"col=email"`
+	Name  string `db:"col=name,null"`
+}
+
+type crudMembership struct {
+	GroupID int64  `db:"database=app,table=memberships,col=group_id,pk"`
+	UserID  int64  `db:"col=user_id,pk"`
+	Role    string `db:"col=role"`
+}
+
+// newCrudRdb returns an Rdb with its own registry of the crud test models
+// backed by a fake database answering with responses.
+func newCrudRdb(t *testing.T, responses ...fakeResponse) (*Rdb, *fakeServer) {
+	t.Helper()
+	reg := NewRegistry()
+	for _, m := range []interface{}{crudUser{}, crudMembership{}} {
+		if e := reg.Register(m); e != nil {
+			t.Fatalf("Not expecting error registering %T but got: %s", m, e.Error())
+		}
+	}
+	db, srv := newFakeDB(t, responses...)
+	return &Rdb{Db: db, Registry: reg}, srv
+}
+
+func TestInsertBackFillsAutoIncrement(t *testing.T) {
+	db, srv := newCrudRdb(t, fakeResponse{lastInsertID: 42, rowsAffected: 1})
+	u := crudUser{Email: "a@example.com", Name: "A"}
+	if err := db.Insert(context.Background(), &u); err != nil {
+		t.Fatalf("Not expecting error on Insert but got: %s", err.Error())
+	}
+
+	q := "INSERT INTO `app`.`users` (`email`, `name`) VALUES (?, ?)"
+	c := srv.call(0)
+	if c.query != q {
+		t.Errorf("Expected: %s Got: %s", q, c.query)
+	}
+
+	if !reflect.DeepEqual(c.args, []driver.Value{"a@example.com", "A"}) {
+		t.Errorf("Expected args [a@example.com A], got %v", c.args)
+	}
+
+	if u.ID != 42 {
+		t.Errorf("Expected ID to be back-filled with 42, got %d", u.ID)
+	}
+}
+
+func TestInsertWithoutAutoIncrement(t *testing.T) {
+	db, srv := newCrudRdb(t)
+	m := crudMembership{GroupID: 1, UserID: 2, Role: "admin"}
+	if err := db.Insert(context.Background(), &m); err != nil {
+		t.Fatalf("Not expecting error on Insert but got: %s", err.Error())
+	}
+
+	q := "INSERT INTO `app`.`memberships` (`group_id`, `user_id`, `role`) VALUES (?, ?, ?)"
+	if c := srv.call(0); c.query != q {
+		t.Errorf("Expected: %s Got: %s", q, c.query)
+	}
+}
+
+func TestInsertErrors(t *testing.T) {
+	db, _ := newCrudRdb(t)
+	if err := db.Insert(context.Background(), crudUser{}); err == nil {
+		t.Errorf("Expected error inserting a model by value")
+	}
+
+	type unregistered struct {
+		ID int `db:"database=app,table=nope,col=id"`
+	}
+	if err := db.Insert(context.Background(), &unregistered{}); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Expected ErrNotRegistered, got %v", err)
+	}
+
+	fail := errors.New("connection lost")
+	db, _ = newCrudRdb(t, fakeResponse{err: fail})
+	if err := db.Insert(context.Background(), &crudUser{}); !errors.Is(err, fail) {
+		t.Errorf("Expected driver error, got %v", err)
+	}
+}
+
+func TestSetID(t *testing.T) {
+	var i8 int8
+	if err := setID(reflect.ValueOf(&i8).Elem(), 300); err == nil {
+		t.Errorf("Expected overflow error setting 300 on int8")
+	}
+
+	var p *int64
+	if err := setID(reflect.ValueOf(&p).Elem(), 7); err != nil || p == nil || *p != 7 {
+		t.Errorf("Expected pointer field to be allocated and set to 7")
+	}
+
+	var s string
+	if err := setID(reflect.ValueOf(&s).Elem(), 7); err == nil {
+		t.Errorf("Expected error setting ID on string field")
+	}
+}
+
+func TestGetByModelKey(t *testing.T) {
+	db, srv := newCrudRdb(t, fakeResponse{
+		columns: []string{"id", "email", "name"},
+		rows:    [][]driver.Value{{int64(7), "a@example.com", nil}},
+	})
+	u := crudUser{ID: 7, Name: "stale"}
+	if err := db.Get(context.Background(), &u); err != nil {
+		t.Fatalf("Not expecting error on Get but got: %s", err.Error())
+	}
+
+	q := "SELECT `id`, `email`, `name` FROM `app`.`users` WHERE `id` = ? LIMIT 1"
+	c := srv.call(0)
+	if c.query != q {
+		t.Errorf("Expected: %s Got: %s", q, c.query)
+	}
+
+	if !reflect.DeepEqual(c.args, []driver.Value{int64(7)}) {
+		t.Errorf("Expected args [7], got %v", c.args)
+	}
+
+	if u.Email != "a@example.com" || u.Name != "" {
+		t.Errorf("Expected email loaded and NULL name cleared, got %+v", u)
+	}
+}
+
+func TestGetByCompositeKeys(t *testing.T) {
+	db, srv := newCrudRdb(t, fakeResponse{
+		columns: []string{"group_id", "user_id", "role"},
+		rows:    [][]driver.Value{{int64(1), int64(2), "admin"}},
+	})
+	var m crudMembership
+	if err := db.Get(context.Background(), &m, 1, 2); err != nil {
+		t.Fatalf("Not expecting error on Get but got: %s", err.Error())
+	}
+
+	q := "SELECT `group_id`, `user_id`, `role` FROM `app`.`memberships` WHERE `group_id` = ? AND `user_id` = ? LIMIT 1"
+	if c := srv.call(0); c.query != q {
+		t.Errorf("Expected: %s Got: %s", q, c.query)
+	}
+
+	if m.Role != "admin" || m.UserID != 2 {
+		t.Errorf("Expected membership to be loaded, got %+v", m)
+	}
+
+	if err := db.Get(context.Background(), &m, 1); err == nil {
+		t.Errorf("Expected error on Get with too few key values")
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	db, _ := newCrudRdb(t, fakeResponse{columns: []string{"id", "email", "name"}})
+	err := db.Get(context.Background(), &crudUser{}, 9)
+
+	var nf *NotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("Expected *NotFoundError, got %T: %v", err, err)
+	}
+
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected not found error to match ErrNotFound and sql.ErrNoRows")
+	}
+
+	if nf.Table.String() != "app.users" || !reflect.DeepEqual(nf.Keys, []interface{}{9}) {
+		t.Errorf("Expected not found in app.users for key [9], got %s %v", nf.Table, nf.Keys)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	db, srv := newCrudRdb(t, fakeResponse{rowsAffected: 1})
+	m := crudMembership{GroupID: 1, UserID: 2, Role: "owner"}
+	n, err := db.Update(context.Background(), &m)
+	if err != nil {
+		t.Fatalf("Not expecting error on Update but got: %s", err.Error())
+	}
+
+	if n != 1 {
+		t.Errorf("Expected 1 row affected, got %d", n)
+	}
+
+	q := "UPDATE `app`.`memberships` SET `role` = ? WHERE `group_id` = ? AND `user_id` = ?"
+	c := srv.call(0)
+	if c.query != q {
+		t.Errorf("Expected: %s Got: %s", q, c.query)
+	}
+
+	if !reflect.DeepEqual(c.args, []driver.Value{"owner", int64(1), int64(2)}) {
+		t.Errorf("Expected args [owner 1 2], got %v", c.args)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	db, srv := newCrudRdb(t, fakeResponse{rowsAffected: 1}, fakeResponse{rowsAffected: 0})
+	n, err := db.Delete(context.Background(), &crudUser{ID: 3})
+	if err != nil {
+		t.Fatalf("Not expecting error on Delete but got: %s", err.Error())
+	}
+
+	if n != 1 {
+		t.Errorf("Expected 1 row affected, got %d", n)
+	}
+
+	q := "DELETE FROM `app`.`users` WHERE `id` = ?"
+	if c := srv.call(0); c.query != q {
+		t.Errorf("Expected: %s Got: %s", q, c.query)
+	}
+
+	if _, err := db.Delete(context.Background(), &crudUser{ID: 3}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing row, got %v", err)
+	}
+}
+
+func TestKeyedOperationsRequirePrimaryKey(t *testing.T) {
+	type keyless struct {
+		Name string `db:"database=app,table=keyless,col=name"`
+	}
+	db, _ := newCrudRdb(t)
+	if e := db.Registry.Register(keyless{}); e != nil {
+		t.Fatalf("Not expecting error registering keyless but got: %s", e.Error())
+	}
+
+	if err := db.Get(context.Background(), &keyless{}); err == nil {
+		t.Errorf("Expected error on Get for model without primary key")
+	}
+	if _, err := db.Update(context.Background(), &keyless{}); err == nil {
+		t.Errorf("Expected error on Update for model without primary key")
+	}
+	if _, err := db.Delete(context.Background(), &keyless{}); err == nil {
+		t.Errorf("Expected error on Delete for model without primary key")
+	}
+}
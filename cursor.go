@@ -0,0 +1,142 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"reflect"
+)
+
+// Cursor scans the rows of a query into registered model T one at a time, so
+// a large result is never held in memory at once. Every row is scanned into
+// the same struct. A Cursor is not safe for concurrent use.
+//
+//	c, err := rdb.QueryCursor[User](ctx, db, "SELECT * FROM users")
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	var u User
+//	for c.Next() {
+//		if err := c.Scan(&u); err != nil {
+//			return err
+//		}
+//	}
+//	return c.Err()
+type Cursor[T any] struct {
+	ctx     context.Context
+	rows    *sql.Rows
+	m       T
+	targets []interface{}
+	finish  func()
+	err     error
+}
+
+// QueryCursor runs a SELECT query and returns a Cursor over its rows as
+// registered model T, a model struct. Result columns are mapped to fields as
+// by Rdb.Select. The Cursor must be closed once done with.
+func QueryCursor[T any](ctx context.Context, s Session, query string, args ...interface{}) (*Cursor[T], error) {
+	c := &Cursor[T]{ctx: ctx}
+	v := reflect.ValueOf(&c.m).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Cursor requires a model struct, %s given", v.Type())
+	}
+
+	q, reg, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := reg.lookup(v.Type())
+	if !ok {
+		return nil, fmt.Errorf(`%w: "%s"`, ErrNotRegistered, typeName(v.Type()))
+	}
+
+	rows, cols, err := queryModel(ctx, q, reg, t, query, args)
+	if err != nil {
+		return nil, err
+	}
+	c.rows = rows
+	c.targets, c.finish = fieldTargets(v, cols)
+	return c, nil
+}
+
+// Next scans the next row, reporting whether there was one. It returns false
+// once the rows are exhausted, on a problem scanning a row or when the
+// context of the query is done, see Err. The rows are closed when Next
+// returns false.
+func (c *Cursor[T]) Next() bool {
+	if c.err != nil {
+		return false
+	}
+	if c.err = c.ctx.Err(); c.err != nil {
+		c.Close()
+		return false
+	}
+	if !c.rows.Next() {
+		c.err = c.rows.Err()
+		c.Close()
+		return false
+	}
+	if c.err = c.rows.Scan(c.targets...); c.err != nil {
+		c.Close()
+		return false
+	}
+	c.finish()
+	return true
+}
+
+// Scan copies the row scanned by the last call to Next into dest.
+func (c *Cursor[T]) Scan(dest *T) error {
+	if c.err != nil {
+		return c.err
+	}
+	if dest == nil {
+		return fmt.Errorf("Scan requires a pointer to a model struct, nil given")
+	}
+	*dest = c.m
+	return nil
+}
+
+// Err returns the problem which ended the iteration, if any.
+func (c *Cursor[T]) Err() error {
+	return c.err
+}
+
+// Close closes the rows of the cursor. It may be called more than once.
+func (c *Cursor[T]) Close() error {
+	return c.rows.Close()
+}
+
+// Stream runs a SELECT query and returns an iterator over its rows as
+// registered model T, see QueryCursor. The rows are closed when the loop ends,
+// including when it breaks early. A problem running the query or scanning a
+// row is yielded with the zero T and ends the iteration:
+//
+//	for u, err := range rdb.Stream[User](ctx, db, "SELECT * FROM users") {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Stream[T any](ctx context.Context, s Session, query string, args ...interface{}) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		c, err := QueryCursor[T](ctx, s, query, args...)
+		if err != nil {
+			var m T
+			yield(m, err)
+			return
+		}
+		defer c.Close()
+
+		for c.Next() {
+			if !yield(c.m, nil) {
+				return
+			}
+		}
+		if err := c.Err(); err != nil {
+			var m T
+			yield(m, err)
+		}
+	}
+}
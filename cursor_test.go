@@ -0,0 +1,130 @@
+package rdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+// userRows returns the response of a query selecting n users.
+func userRows(n int) fakeResponse {
+	r := fakeResponse{columns: []string{"id", "email", "name"}}
+	for i := 1; i <= n; i++ {
+		var name driver.Value
+		if i%2 == 0 {
+			name = "even"
+		}
+		r.rows = append(r.rows, []driver.Value{int64(i), "u@example.com", name})
+	}
+	return r
+}
+
+func TestCursorScansEveryRow(t *testing.T) {
+	db, srv := newCrudRdb(t, userRows(3))
+	c, err := QueryCursor[crudUser](context.Background(), db, "SELECT * FROM users")
+	if err != nil {
+		t.Fatalf("Not expecting error opening cursor but got: %s", err.Error())
+	}
+	defer c.Close()
+
+	var got []crudUser
+	var u crudUser
+	for c.Next() {
+		if err := c.Scan(&u); err != nil {
+			t.Fatalf("Not expecting error on Scan but got: %s", err.Error())
+		}
+		got = append(got, u)
+	}
+	if err := c.Err(); err != nil {
+		t.Fatalf("Not expecting error iterating but got: %s", err.Error())
+	}
+
+	// A NULL name after a non NULL one is scanned as empty on the reused struct
+	if len(got) != 3 || got[1].Name != "even" || got[2] != (crudUser{ID: 3, Email: "u@example.com"}) {
+		t.Errorf("Expected three users, got %+v", got)
+	}
+	if n := srv.closedRows(); n != 1 {
+		t.Errorf("Expected the rows to be closed once exhausted, %d closed", n)
+	}
+	if c.Next() {
+		t.Errorf("Not expecting Next to succeed once exhausted")
+	}
+}
+
+func TestCursorErrors(t *testing.T) {
+	db, _ := newCrudRdb(t)
+	if _, err := QueryCursor[*crudUser](context.Background(), db, "SELECT * FROM users"); err == nil {
+		t.Errorf("Expected error opening a cursor of pointers")
+	}
+	type unregistered struct{}
+	if _, err := QueryCursor[unregistered](context.Background(), db, "SELECT 1"); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Expected ErrNotRegistered, got %v", err)
+	}
+	if _, err := QueryCursor[crudUser](context.Background(), db, "DELETE FROM users"); err == nil {
+		t.Errorf("Expected error opening a cursor on a DELETE")
+	}
+}
+
+func TestCursorHonoursCancellation(t *testing.T) {
+	db, srv := newCrudRdb(t, userRows(3))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := QueryCursor[crudUser](ctx, db, "SELECT * FROM users")
+	if err != nil {
+		t.Fatalf("Not expecting error opening cursor but got: %s", err.Error())
+	}
+
+	n := 0
+	for c.Next() {
+		if n++; n == 2 {
+			cancel()
+		}
+	}
+	if n != 2 || !errors.Is(c.Err(), context.Canceled) {
+		t.Errorf("Expected cancellation after 2 rows, got %d rows and error %v", n, c.Err())
+	}
+	var u crudUser
+	if err := c.Scan(&u); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Scan to report cancellation, got %v", err)
+	}
+	if n := srv.closedRows(); n != 1 {
+		t.Errorf("Expected the rows to be closed on cancellation, %d closed", n)
+	}
+}
+
+func TestStream(t *testing.T) {
+	db, srv := newCrudRdb(t, userRows(5), userRows(2))
+	var ids []uint32
+	for u, err := range Stream[crudUser](context.Background(), db, "SELECT * FROM users") {
+		if err != nil {
+			t.Fatalf("Not expecting error streaming but got: %s", err.Error())
+		}
+		if ids = append(ids, u.ID); len(ids) == 2 {
+			break
+		}
+	}
+	if len(ids) != 2 || ids[1] != 2 {
+		t.Errorf("Expected users 1 and 2, got %v", ids)
+	}
+	if n := srv.closedRows(); n != 1 {
+		t.Errorf("Expected the rows to be closed on break, %d closed", n)
+	}
+
+	// The single connection is free again
+	users, err := Query[crudUser](context.Background(), db, "SELECT * FROM users")
+	if err != nil || len(users) != 2 {
+		t.Errorf("Expected two users after streaming, got %v and error %v", users, err)
+	}
+
+	var errs int
+	for _, err := range Stream[crudUser](context.Background(), db, "UPDATE users SET name = ''") {
+		if err == nil {
+			t.Errorf("Expected error streaming an UPDATE")
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Errorf("Expected a single error, got %d", errs)
+	}
+}
@@ -0,0 +1,213 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeResponse scripts the outcome of a single statement run against the
+// fake driver.
+type fakeResponse struct {
+	columns      []string
+	rows         [][]driver.Value
+	lastInsertID int64
+	rowsAffected int64
+	err          error
+}
+
+// fakeCall records a statement run against the fake driver. Transaction
+// control is recorded as BEGIN, COMMIT and ROLLBACK statements.
+type fakeCall struct {
+	query string
+	args  []driver.Value
+}
+
+// fakeServer holds the script and call log of a fake database.
+type fakeServer struct {
+	mu        sync.Mutex
+	responses []fakeResponse
+	calls     []fakeCall
+	closed    int // Number of closed result sets
+}
+
+var (
+	fakeServersMu sync.Mutex
+	fakeServers   = make(map[string]*fakeServer)
+)
+
+func init() {
+	sql.Register("rdbtest", fakeDriver{})
+}
+
+// newFakeDB opens a database backed by the fake driver which answers
+// statements with the scripted responses in order. Statements run once the
+// script is exhausted succeed without returning rows.
+func newFakeDB(t *testing.T, responses ...fakeResponse) (*sql.DB, *fakeServer) {
+	t.Helper()
+	srv := &fakeServer{responses: responses}
+	fakeServersMu.Lock()
+	name := t.Name() + "#" + strconv.Itoa(len(fakeServers))
+	fakeServers[name] = srv
+	fakeServersMu.Unlock()
+
+	db, err := sql.Open("rdbtest", name)
+	if err != nil {
+		t.Fatalf("Not expecting error opening fake database but got: %s", err.Error())
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db, srv
+}
+
+// queries returns the statements run against the server.
+func (s *fakeServer) queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := make([]string, len(s.calls))
+	for i, c := range s.calls {
+		q[i] = c.query
+	}
+	return q
+}
+
+// call returns the i-th statement run against the server.
+func (s *fakeServer) call(i int) fakeCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i >= len(s.calls) {
+		return fakeCall{}
+	}
+	return s.calls[i]
+}
+
+// closedRows returns the number of result sets closed so far.
+func (s *fakeServer) closedRows() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *fakeServer) next(query string, args []driver.NamedValue) fakeResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vals := make([]driver.Value, len(args))
+	for i, a := range args {
+		vals[i] = a.Value
+	}
+	s.calls = append(s.calls, fakeCall{query, vals})
+	if len(s.responses) == 0 {
+		return fakeResponse{}
+	}
+	r := s.responses[0]
+	s.responses = s.responses[1:]
+	return r
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeServersMu.Lock()
+	defer fakeServersMu.Unlock()
+	srv, ok := fakeServers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown fake server %q", name)
+	}
+	return &fakeConn{srv}, nil
+}
+
+type fakeConn struct {
+	srv *fakeServer
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("fake driver does not prepare statements")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if r := c.srv.next("BEGIN", nil); r.err != nil {
+		return nil, r.err
+	}
+	return fakeTx{c.srv}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	r := c.srv.next(query, args)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return fakeResult{r.lastInsertID, r.rowsAffected}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r := c.srv.next(query, args)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &fakeRows{srv: c.srv, columns: r.columns, rows: r.rows}, nil
+}
+
+type fakeTx struct {
+	srv *fakeServer
+}
+
+func (tx fakeTx) Commit() error {
+	return tx.srv.next("COMMIT", nil).err
+}
+
+func (tx fakeTx) Rollback() error {
+	return tx.srv.next("ROLLBACK", nil).err
+}
+
+type fakeResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type fakeRows struct {
+	srv     *fakeServer
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	r.srv.mu.Lock()
+	r.srv.closed++
+	r.srv.mu.Unlock()
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
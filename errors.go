@@ -0,0 +1,226 @@
+package rdb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kinds of problems found when registering a model. Every FieldError wraps
+// one of them so problems can be told apart with errors.Is on the error
+// returned by Register.
+var (
+	ErrNotStruct       = errors.New("Model is not a struct type")
+	ErrNoColumns       = errors.New("No columns defined")
+	ErrMissingDatabase = errors.New("Database name not defined")
+	ErrMissingTable    = errors.New("Table name not defined")
+	ErrMissingColumn   = errors.New("Column name not defined")
+	ErrDuplicateColumn = errors.New("Duplicate column name")
+	ErrRedeclared      = errors.New("Option redeclared")
+	ErrInvalidName     = errors.New("Invalid name")
+	ErrBadTag          = errors.New("Invalid db tag option")
+	ErrBadFKMap        = errors.New("Invalid foreign-key map")
+	ErrBadPrimaryKey   = errors.New("Invalid primary key")
+	ErrBadPrefix       = errors.New("Invalid column prefix")
+	ErrUnexported      = errors.New("Unexported field")
+	ErrTableConflict   = errors.New("Table registered to another model")
+	ErrSealed          = errors.New("Registry is sealed")
+)
+
+// Kinds of problems found when validating the relationships between the
+// models of a Registry, see Registry.Validate.
+var (
+	ErrDanglingFKMap  = errors.New("Foreign-key map references an unknown model or field")
+	ErrAmbiguousFKMap = errors.New("Foreign-key map references an ambiguous model")
+	ErrFKMapKind      = errors.New("Foreign-key map kind mismatch")
+	ErrFKMapCycle     = errors.New("Foreign-key map cycle")
+)
+
+// Kinds of problems found when lexing a query. Every TokenError wraps one of
+// them.
+var (
+	ErrIllegalToken = errors.New("Illegal token")
+	ErrUnterminated = errors.New("Unterminated token")
+)
+
+// FieldError describes a single problem found when registering or validating
+// a model.
+type FieldError struct {
+	Model   string // Name of the model the problem was found in
+	Field   string // Field path of the offending field, empty for model problems
+	Segment string // Offending db tag segment, empty if not caused by one
+	Kind    error  // One of the Err* problem kinds
+	msg     string
+}
+
+// Error returns the description of the problem.
+func (e *FieldError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the kind of the problem.
+func (e *FieldError) Unwrap() error {
+	return e.Kind
+}
+
+// RegistrationError collects every problem found when registering a model.
+// errors.Is reports true for the kind of any of the collected problems.
+type RegistrationError struct {
+	Model  string        // Name of the model being registered
+	Errors []*FieldError // Problems in the order they were found
+}
+
+// Error returns the description of the problem when a single one was found,
+// or a list of every problem otherwise.
+func (e *RegistrationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = "\t" + fe.Error()
+	}
+	return fmt.Sprintf("%d errors registering %s:\n%s", len(e.Errors), e.Model, strings.Join(msgs, "\n"))
+}
+
+// Unwrap returns the collected problems.
+func (e *RegistrationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// ValidationError collects every problem found when validating the models of
+// a Registry. errors.Is reports true for the kind of any of the collected
+// problems.
+type ValidationError struct {
+	Errors []*FieldError // Problems ordered by model and field
+}
+
+// Error returns the description of the problem when a single one was found,
+// or a list of every problem otherwise.
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = "\t" + fe.Error()
+	}
+	return fmt.Sprintf("%d errors validating registry:\n%s", len(e.Errors), strings.Join(msgs, "\n"))
+}
+
+// Unwrap returns the collected problems.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// add records a problem of kind found on a field and tag segment.
+func (e *RegistrationError) add(kind error, field, segment, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, &FieldError{
+		Model:   e.Model,
+		Field:   field,
+		Segment: segment,
+		Kind:    kind,
+		msg:     fmt.Sprintf(format, args...),
+	})
+}
+
+// err returns e if any problem was recorded, or nil.
+func (e *RegistrationError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// TokenError describes an illegal token found when lexing a query, or the
+// token a statement could not be parsed at.
+type TokenError struct {
+	Value  string // Text of the token
+	Pos    int    // Byte offset of the token in the query
+	Line   int    // Line of the token, starting at 1
+	Col    int    // Column of the token in characters, starting at 1
+	Kind   error  // ErrIllegalToken, ErrUnterminated or ErrSyntax
+	query  string
+	detail string
+}
+
+// Error returns the description of the problem followed by an excerpt of the
+// query pointing at the token.
+func (e *TokenError) Error() string {
+	msg := fmt.Sprintf("%s %q at line %d, column %d", e.Kind, e.Value, e.Line, e.Col)
+	if e.Value == "" {
+		msg = fmt.Sprintf("%s at end of query, line %d, column %d", e.Kind, e.Line, e.Col)
+	}
+	if e.detail != "" {
+		msg += ": " + e.detail
+	}
+	return msg + "\n" + e.Excerpt()
+}
+
+// Unwrap returns the kind of the problem.
+func (e *TokenError) Unwrap() error {
+	return e.Kind
+}
+
+// Excerpt returns the line of the query holding the token with a caret
+// underneath its first character.
+func (e *TokenError) Excerpt() string {
+	start := strings.LastIndexByte(e.query[:e.Pos], '\n') + 1
+	end := strings.IndexByte(e.query[e.Pos:], '\n')
+	if end < 0 {
+		end = len(e.query)
+	} else {
+		end += e.Pos
+	}
+
+	// Keep tabs so the caret lines up with the token
+	var caret strings.Builder
+	for _, ch := range e.query[start:e.Pos] {
+		if ch == '\t' {
+			caret.WriteRune(ch)
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	return "\t" + e.query[start:end] + "\n\t" + caret.String() + "^"
+}
+
+// LexError collects every illegal token found when lexing a query.
+// errors.Is reports true for the kind of any of the collected problems.
+type LexError struct {
+	Query  string        // The lexed query
+	Errors []*TokenError // Problems in query order
+}
+
+// Error returns the description of the problem when a single one was found,
+// or a list of every problem otherwise.
+func (e *LexError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	msgs := make([]string, len(e.Errors))
+	for i, te := range e.Errors {
+		msgs[i] = te.Error()
+	}
+	return fmt.Sprintf("%d errors lexing query:\n%s", len(e.Errors), strings.Join(msgs, "\n"))
+}
+
+// Unwrap returns the collected problems.
+func (e *LexError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, te := range e.Errors {
+		errs[i] = te
+	}
+	return errs
+}
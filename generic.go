@@ -0,0 +1,61 @@
+package rdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Session runs statements against the models of a Registry. It is
+// implemented by *Rdb and *Tx.
+type Session interface {
+	session() (querier, *Registry, error)
+}
+
+func (r *Rdb) session() (querier, *Registry, error) {
+	return r.Db, r.registry(), nil
+}
+
+// Get returns the row of registered model T with the given primary key
+// values, in key order. A *NotFoundError is returned when no row matches.
+func Get[T any](ctx context.Context, s Session, keys ...interface{}) (T, error) {
+	var m T
+	if len(keys) == 0 {
+		return m, fmt.Errorf(`Get requires primary key values for model "%s"`, typeName(reflect.TypeOf(m)))
+	}
+	q, reg, err := s.session()
+	if err != nil {
+		return m, err
+	}
+	err = get(ctx, q, reg, &m, keys)
+	return m, err
+}
+
+// All returns every row of the table of registered model T.
+func All[T any](ctx context.Context, s Session) ([]T, error) {
+	var m T
+	_, reg, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	t, err := reg.table(m)
+	if err != nil {
+		return nil, err
+	}
+	return Query[T](ctx, s, t.allQuery())
+}
+
+// Query runs a SELECT query and returns its rows as registered model T, see
+// Rdb.Select for how result columns are mapped to fields. The analysis of
+// the query is cached, so running it again only scans its rows.
+func Query[T any](ctx context.Context, s Session, query string, args ...interface{}) ([]T, error) {
+	q, reg, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	var ms []T
+	if err := selectInto(ctx, q, reg, &ms, query, args); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
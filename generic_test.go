@@ -0,0 +1,118 @@
+package rdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestGenericGet(t *testing.T) {
+	db, srv := newCrudRdb(t, fakeResponse{
+		columns: []string{"id", "email", "name"},
+		rows:    [][]driver.Value{{int64(7), "a@example.com", "A"}},
+	})
+
+	u, err := Get[crudUser](context.Background(), db, 7)
+	if err != nil {
+		t.Fatalf("Not expecting error on Get but got: %s", err.Error())
+	}
+	if u != (crudUser{ID: 7, Email: "a@example.com", Name: "A"}) {
+		t.Errorf("Expected user 7, got %+v", u)
+	}
+	if c := srv.call(0); !reflect.DeepEqual(c.args, []driver.Value{int64(7)}) {
+		t.Errorf("Expected Get by key 7, got %v", c.args)
+	}
+
+	if _, err := Get[crudUser](context.Background(), db); err == nil {
+		t.Errorf("Expected error on Get without keys")
+	}
+	if _, err := Get[crudMembership](context.Background(), db, 1); err == nil {
+		t.Errorf("Expected error on Get with too few keys")
+	}
+}
+
+func TestGenericAllAndQuery(t *testing.T) {
+	db, srv := newCrudRdb(t,
+		fakeResponse{
+			columns: []string{"id", "email", "name"},
+			rows:    [][]driver.Value{{int64(1), "a@example.com", nil}, {int64(2), "b@example.com", "B"}},
+		},
+		fakeResponse{
+			columns: []string{"group_id", "user_id", "role"},
+			rows:    [][]driver.Value{{int64(1), int64(2), "admin"}},
+		},
+		fakeResponse{
+			columns: []string{"group_id", "user_id", "role"},
+		},
+	)
+
+	users, err := All[crudUser](context.Background(), db)
+	if err != nil {
+		t.Fatalf("Not expecting error on All but got: %s", err.Error())
+	}
+	if len(users) != 2 || users[1].Name != "B" {
+		t.Errorf("Expected two users, got %+v", users)
+	}
+	query := "SELECT `id`, `email`, `name` FROM `app`.`users`"
+	if c := srv.call(0); c.query != query {
+		t.Errorf("Expected:\n%s\nGot:\n%s", query, c.query)
+	}
+
+	query = "SELECT * FROM memberships WHERE role = ?"
+	for i, n := range []int{1, 0} {
+		ms, err := Query[*crudMembership](context.Background(), db, query, "admin")
+		if err != nil {
+			t.Fatalf("Not expecting error on Query but got: %s", err.Error())
+		}
+		if len(ms) != n {
+			t.Errorf("Expected %d memberships on run %d, got %+v", n, i, ms)
+		}
+	}
+
+	// Both queries are analyzed once
+	if l := len(db.Registry.selects); l != 2 {
+		t.Errorf("Expected 2 cached query analyses, got %d", l)
+	}
+	if _, err := Query[crudUser](context.Background(), db, "UPDATE users SET name = ''"); err == nil {
+		t.Errorf("Expected error on Query of an UPDATE")
+	}
+
+	type genericGroup struct {
+		ID int64 `db:"database=app,table=groups,col=id,pk"`
+	}
+	if e := db.Registry.Register(genericGroup{}); e != nil {
+		t.Fatalf("Not expecting error registering a group but got: %s", e.Error())
+	}
+	if db.Registry.selects != nil {
+		t.Errorf("Expected registering a model to reset the cached query analyses")
+	}
+}
+
+func TestStaleAnalysisIsNotCached(t *testing.T) {
+	reg := newBuilderRegistry(t)
+	query := "SELECT * FROM users"
+	reg.mu.RLock()
+	gen := reg.gen
+	reg.mu.RUnlock()
+	results, err := reg.analyzeSelect(query)
+	if err != nil {
+		t.Fatalf("Not expecting error analyzing %q but got: %s", query, err.Error())
+	}
+
+	// A model registered while the query was analyzed
+	if e := reg.Register(crudMembership{}); e != nil {
+		t.Fatalf("Not expecting error registering a membership but got: %s", e.Error())
+	}
+	reg.cacheSelect(query, gen, results)
+	if _, ok := reg.selects[query]; ok {
+		t.Errorf("Expected an analysis made before a model was registered not to be cached")
+	}
+
+	if _, err := reg.analyzeSelect(query); err != nil {
+		t.Fatalf("Not expecting error analyzing %q but got: %s", query, err.Error())
+	}
+	if _, ok := reg.selects[query]; !ok {
+		t.Errorf("Expected a fresh analysis to be cached")
+	}
+}
@@ -1,22 +1,27 @@
 package rdb
 
 import (
-	"bytes"
 	"strings"
 	"unicode/utf8"
 )
 
-var eof rune
+// eof is returned by read at the end of the input. It is not a valid rune,
+// so a NUL character in the input is lexed as any other.
+const eof rune = -1
 
-// item represents a token and a literal value
+// item represents a token, its literal value and its position in the query
 type item struct {
 	Token token
 	Value string
+	Pos   int // Byte offset of the token in the query
+	Line  int // Line of the token, starting at 1
+	Col   int // Column of the token in characters, starting at 1
 }
 
 type stateFn func(*lexer) stateFn
 
-// lexer represents a query to be lexed and provides a buffered Reader for lexing.
+// lexer represents a query to be lexed. Tokens are lexed on demand by the
+// state functions as they are pulled with Next.
 type lexer struct {
 	name   string
 	input  string
@@ -24,81 +29,160 @@ type lexer struct {
 	start  int
 	pos    int
 	width  int
-	items  chan item
+	state  stateFn
+	prev   token // Last token scanned other than whitespace
+	line   int   // Line of the current position
+	col    int   // Column of the current position
+	reason error // Kind of problem of the illegal token being scanned
+	errs   []*TokenError
+	items  []item // Lexed items, those from head on are not yet returned by Next
+	head   int
 }
 
-func lexSelect(l *lexer) stateFn {
-	l.pos += len(selectStmt)
-	l.emit(selectToken)
+// lexStatement lexes the keyword leading a statement and any whitespace
+// before it.
+func lexStatement(l *lexer) stateFn {
+	switch l.lexItem().Token {
+	case EOF:
+		return nil
+	case WS:
+		return lexStatement
+	case selectToken:
+		return lexSelect
+	case insertToken, replaceToken:
+		return lexInsert
+	case updateToken:
+		return lexUpdate
+	case deleteToken:
+		return lexDelete
+	}
+	return lexTokens
+}
 
-	return lexFrom
+// lexSelect lexes the select list of a SELECT statement, up to FROM.
+func lexSelect(l *lexer) stateFn {
+	switch l.lexItem().Token {
+	case EOF:
+		return nil
+	case fromToken:
+		return lexFrom
+	}
+	return lexSelect
 }
 
+// lexInsert lexes the target table and columns of an INSERT or REPLACE
+// statement, up to the VALUES, SET or SELECT giving the inserted rows.
 func lexInsert(l *lexer) stateFn {
-	l.pos += len(insertStmt)
-	l.emit(insertToken)
+	switch l.lexItem().Token {
+	case EOF:
+		return nil
+	case valuesToken:
+		return lexValues
+	case setToken:
+		return lexSet
+	case selectToken:
+		return lexSelect
+	}
+	return lexInsert
+}
 
-	return lexValues
+// lexUpdate lexes the table references of an UPDATE statement, up to SET.
+func lexUpdate(l *lexer) stateFn {
+	switch l.lexItem().Token {
+	case EOF:
+		return nil
+	case setToken:
+		return lexSet
+	}
+	return lexUpdate
 }
 
-func lexFrom(l *lexer) stateFn {
-	l.pos += len(fromStmt)
-	l.emit(fromToken)
+// lexDelete lexes the tables of a DELETE statement, up to FROM.
+func lexDelete(l *lexer) stateFn {
+	switch l.lexItem().Token {
+	case EOF:
+		return nil
+	case fromToken:
+		return lexFrom
+	}
+	return lexDelete
+}
 
-	return lexDump
+// lexFrom lexes the table references of a FROM clause and the remainder of
+// the statement.
+func lexFrom(l *lexer) stateFn {
+	return lexTokens
 }
 
+// lexValues lexes the rows of an INSERT statement, up to ON DUPLICATE KEY
+// UPDATE.
 func lexValues(l *lexer) stateFn {
-
-	return lexDump
+	switch l.lexItem().Token {
+	case EOF:
+		return nil
+	case onDuplicateKeyUpdateToken:
+		return lexSet
+	}
+	return lexValues
 }
 
-func lexDump(l *lexer) stateFn {
-	l.dump()
-	return nil
+// lexSet lexes the assignments of a SET or ON DUPLICATE KEY UPDATE clause and
+// the remainder of the statement.
+func lexSet(l *lexer) stateFn {
+	return lexTokens
 }
 
-func lexStatement(l *lexer) stateFn {
-	if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), selectStmt) {
-		return lexSelect
+// lexTokens lexes the remaining tokens of a statement.
+func lexTokens(l *lexer) stateFn {
+	if l.lexItem().Token == EOF {
+		return nil
 	}
-	if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), insertStmt) {
-		return lexInsert
-	}
-	if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), fromStmt) {
-		return lexFrom
-	}
-	l.dump()
-	return nil
+	return lexTokens
 }
 
-// NewLexer creates and returns a new lexer scanner from the provided Reader.
-// Reader can be supplied by strings.NewReader(myString)
+// lex creates and returns a new lexer for query. Tokens are pulled from the
+// lexer with Next.
 func lex(query string) *lexer {
-	l := &lexer{
+	return &lexer{
 		input: query,
-		//items: make(chan item),
+		state: lexStatement,
+		line:  1,
+		col:   1,
 	}
-	// go l.run()
-	return l //, l.items
 }
 
-func (l *lexer) run() {
-	for state := lexStatement; state != nil; {
-		state = state(l)
+// Next returns the next token of the query, running the state functions until
+// one is lexed. Once the query is exhausted Next keeps returning EOF.
+func (l *lexer) Next() item {
+	for l.head == len(l.items) {
+		if l.state == nil {
+			return item{Token: EOF, Pos: len(l.input), Line: l.line, Col: l.col}
+		}
+		l.items, l.head = l.items[:0], 0
+		l.state = l.state(l)
 	}
-	close(l.items)
+
+	it := l.items[l.head]
+	l.head++
+	return it
 }
 
-func (l *lexer) emit(t token) {
-	l.items <- item{t, l.input[l.start:l.pos]}
-	l.start = l.pos
+// all returns every remaining token of the query, excluding the final EOF.
+func (l *lexer) all() []item {
+	var items []item
+	for it := l.Next(); it.Token != EOF; it = l.Next() {
+		items = append(items, it)
+	}
+	return items
 }
 
-func (l *lexer) dump() {
-	l.items <- item{unparsed, l.input[l.start:]}
-	l.start = len(l.input)
-	l.items <- item{Token: EOF}
+// lexItem scans the next token at the current position and queues it to be
+// returned by Next.
+func (l *lexer) lexItem() item {
+	it := l.scan()
+	l.items = append(l.items, it)
+	l.start = l.pos
+	return it
 }
 
 // read is used to fetch the next rune in sequence from the buffered Reader.
@@ -124,14 +208,78 @@ func (l *lexer) unread() {
 // alphanumeric sequences to whitespace and identifier scanners, or will return
 // the token type of an individual special token.
 func (l *lexer) scan() item {
+	start := l.pos
+	it := l.scanItem()
+	it.Pos, it.Line, it.Col = start, l.line, l.col
+	if !isBlank(it.Token) {
+		l.prev = it.Token
+	}
+	if it.Token == illegal {
+		l.illegal(it)
+	}
+
+	// Move the line and column past the token
+	v := l.input[start:l.pos]
+	if i := strings.LastIndexByte(v, '\n'); i >= 0 {
+		l.line += strings.Count(v, "\n")
+		l.col = 1
+		v = v[i+1:]
+	}
+	l.col += utf8.RuneCountInString(v)
+	return it
+}
+
+// illegal records the problem of an illegal token.
+func (l *lexer) illegal(it item) {
+	kind := l.reason
+	if kind == nil {
+		kind = ErrIllegalToken
+	}
+	l.reason = nil
+
+	l.errs = append(l.errs, &TokenError{
+		Value: it.Value,
+		Pos:   it.Pos,
+		Line:  it.Line,
+		Col:   it.Col,
+		Kind:  kind,
+		query: l.input,
+	})
+}
+
+// Err returns a *LexError listing every illegal token lexed so far, or nil.
+func (l *lexer) Err() error {
+	if len(l.errs) == 0 {
+		return nil
+	}
+	return &LexError{Query: l.input, Errors: l.errs}
+}
+
+// scanItem scans the token at the current position.
+func (l *lexer) scanItem() item {
+	start := l.pos
 	ch := l.read()
 	if isWhitespace(ch) {
 		l.unread()
 		return l.scanWhitespace()
-	} else if isNumeric(ch) || ch == '-' || ch == '+' {
+	} else if isNumeric(ch) || ((ch == '-' || ch == '+') && l.signed()) || (ch == '.' && l.fraction()) {
 		l.unread()
 		return l.scanNumber()
-	} else if isLetter(ch) {
+	} else if (ch == 'x' || ch == 'X' || ch == 'b' || ch == 'B') && l.peek() == '\'' {
+		return l.scanBinaryString(start)
+	} else if (ch == 'n' || ch == 'N') && l.peek() == '\'' {
+		it := l.scanQuoted()
+		if it.Token == quotedString {
+			it = item{Token: charsetString, Value: l.input[start:l.pos]}
+		}
+		return it
+	} else if ch == '_' {
+		if it, ok := l.scanIntroduced(start); ok {
+			return it
+		}
+		l.pos = start
+		return l.scanKeyword()
+	} else if isLetter(ch) || ch >= utf8.RuneSelf {
 		l.unread()
 		return l.scanKeyword()
 	} else if ch == '\'' || ch == '"' || ch == '`' {
@@ -139,76 +287,285 @@ func (l *lexer) scan() item {
 		return l.scanQuoted()
 	}
 
+	if ch == '#' || l.lineComment(start) {
+		return l.scanLineComment(start)
+	} else if ch == '/' && l.peek() == '*' {
+		return l.scanBlockComment(start)
+	}
+
 	switch ch {
 	case eof:
-		return item{EOF, ""}
+		return item{Token: EOF}
 	case '*':
-		return item{astrisk, string(ch)}
+		return item{Token: astrisk, Value: l.input[start:l.pos]}
 	case ',':
-		return item{comma, string(ch)}
+		return item{Token: comma, Value: l.input[start:l.pos]}
 	case '.':
-		return item{period, string(ch)}
+		return item{Token: period, Value: l.input[start:l.pos]}
 	case '(':
-		return item{lParen, string(ch)}
+		return item{Token: lParen, Value: l.input[start:l.pos]}
 	case ')':
-		return item{rParen, string(ch)}
+		return item{Token: rParen, Value: l.input[start:l.pos]}
 	case '{':
-		return item{lBrace, string(ch)}
+		return item{Token: lBrace, Value: l.input[start:l.pos]}
 	case '}':
-		return item{rBrace, string(ch)}
+		return item{Token: rBrace, Value: l.input[start:l.pos]}
 	case '=':
-		return item{equals, string(ch)}
+		return item{Token: equals, Value: l.input[start:l.pos]}
+	case '@':
+		return l.scanVariable(start)
+	case ':':
+		if isLetter(l.peek()) {
+			l.pos = l.scanWord(l.pos)
+			return item{Token: namedParam, Value: l.input[start:l.pos]}
+		}
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.input[start:], op.op) {
+			l.pos = start + len(op.op)
+			return item{Token: op.t, Value: op.op}
+		}
+	}
+
+	return item{Token: illegal, Value: l.input[start:l.pos]}
+}
+
+// scanLineComment scans a # or -- comment starting at start, up to and
+// including the end of the line.
+func (l *lexer) scanLineComment(start int) item {
+	if i := strings.IndexByte(l.input[start:], '\n'); i >= 0 {
+		l.pos = start + i + 1
+	} else {
+		l.pos = len(l.input)
+	}
+	return item{Token: comment, Value: l.input[start:l.pos]}
+}
+
+// scanBlockComment scans a /* */ comment starting at start. Versioned comments,
+// /*!50700 ... */, and optimizer hints, /*+ ... */, are returned as their own
+// tokens. An unterminated comment is illegal.
+func (l *lexer) scanBlockComment(start int) item {
+	i := strings.Index(l.input[start+2:], "*/")
+	if i < 0 {
+		l.pos = len(l.input)
+		l.reason = ErrUnterminated
+		return item{Token: illegal, Value: l.input[start:]}
+	}
+	l.pos = start + 2 + i + 2
+
+	t := comment
+	switch body := l.input[start+2 : l.pos-2]; {
+	case strings.HasPrefix(body, "!"):
+		t = versionedComment
+	case strings.HasPrefix(body, "+"):
+		t = optimizerHint
+	}
+	return item{Token: t, Value: l.input[start:l.pos]}
+}
+
+// isBlank reports whether t is whitespace or a comment, which do not change
+// the meaning of a statement.
+func isBlank(t token) bool {
+	switch t {
+	case WS, comment, versionedComment, optimizerHint:
+		return true
+	}
+	return false
+}
+
+// peek returns the next rune of the input without consuming it.
+func (l *lexer) peek() rune {
+	width := l.width
+	ch := l.read()
+	l.pos -= l.width
+	l.width = width
+	return ch
+}
+
+// signed reports whether the + or - just read is the sign of a number rather
+// than an operator: it is followed by a decimal digit, or a period and a
+// digit, and does not follow an operand.
+func (l *lexer) signed() bool {
+	digit := l.digitAt(l.pos) || strings.HasPrefix(l.input[l.pos:], ".") && l.digitAt(l.pos+1)
+	return digit && l.prefixed(l.pos) == illegal && !l.operand()
+}
+
+// fraction reports whether the period just read starts a number such as .5
+// rather than qualifying a name: it is followed by a decimal digit and does
+// not follow an operand.
+func (l *lexer) fraction() bool {
+	return l.digitAt(l.pos) && !l.operand()
+}
+
+// digitAt reports whether the character at pos is a decimal digit.
+func (l *lexer) digitAt(pos int) bool {
+	return pos < len(l.input) && isNumeric(rune(l.input[pos]))
+}
+
+// operand reports whether the last token scanned ends an operand.
+func (l *lexer) operand() bool {
+	switch l.prev {
+	case identifier, quotedString, charsetString, naturalNumber, integer, fixedNumber,
+		floatingPointNumber, hexNumber, bitNumber, trueToken, falseToken, nullToken,
+		endToken, rParen, placeholder, namedParam, userVariable, systemVariable:
+		return true
 	}
+	return false
+}
 
-	return item{illegal, string(ch)}
+// prefixed returns hexNumber or bitNumber when a 0x1F or 0b0101 literal starts
+// at pos, or illegal otherwise.
+func (l *lexer) prefixed(pos int) token {
+	if pos+2 >= len(l.input) || l.input[pos] != '0' {
+		return illegal
+	}
+	switch {
+	case l.input[pos+1] == 'x' && isHexDigit(rune(l.input[pos+2])):
+		return hexNumber
+	case l.input[pos+1] == 'b' && isBitDigit(rune(l.input[pos+2])):
+		return bitNumber
+	}
+	return illegal
 }
 
+// scanVariable scans a user variable, @name, @'name' or @`name`, or a system
+// variable, @@name or @@scope.name, following the @ read at start.
+func (l *lexer) scanVariable(start int) item {
+	t := userVariable
+	if l.peek() == '@' {
+		t = systemVariable
+		l.read()
+	}
+
+	ch := l.peek()
+	if t == userVariable && (ch == '\'' || ch == '"' || ch == '`') {
+		if q := l.scanQuoted(); q.Token == illegal {
+			return item{Token: illegal, Value: l.input[start:l.pos]}
+		}
+		return item{Token: t, Value: l.input[start:l.pos]}
+	}
+	if !isAlphanum(ch) && ch != '$' && ch != '.' {
+		return item{Token: illegal, Value: l.input[start:l.pos]}
+	}
+
+	for isAlphanum(ch) || ch == '$' || ch == '.' {
+		l.read()
+		ch = l.peek()
+	}
+	return item{Token: t, Value: l.input[start:l.pos]}
+}
+
+// scanNumber scans a number: 0x1F and 0b0101 literals, or an optionally
+// signed number with an optional fraction and exponent. An e only starts an
+// exponent when digits, optionally signed, follow it.
 func (l *lexer) scanNumber() item {
-	var isNegative, isDecimal, isScientific bool
-	var buf bytes.Buffer
+	start := l.pos
+	if t := l.prefixed(start); t != illegal {
+		digit := isHexDigit
+		if t == bitNumber {
+			digit = isBitDigit
+		}
+		l.pos = l.scanDigits(start+2, digit)
+		return item{Token: t, Value: l.input[start:l.pos]}
+	}
 
-	for {
-		ch := l.read()
-		if ch == eof {
-			break
-		} else if ch == '+' {
-			buf.WriteRune(ch)
-		} else if ch == '-' {
-			buf.WriteRune(ch)
-			if len(buf.String()) == 1 {
-				isNegative = true
-			}
-		} else if ch == '.' {
-			buf.WriteRune(ch)
-			isDecimal = true
-		} else if ch == 'E' || ch == 'e' {
-			buf.WriteRune(ch)
-			isScientific = true
-		} else if isNumeric(ch) {
-			buf.WriteRune(ch)
-		} else if isLetter(ch) || isWhitespace(ch) {
-			l.unread()
-			break
+	t := naturalNumber
+	if ch := l.input[start]; ch == '+' || ch == '-' {
+		if ch == '-' {
+			t = integer
 		}
+		l.pos++
 	}
+	l.pos = l.scanDigits(l.pos, isNumeric)
 
-	var t = floatingPointNumber
-	if !isDecimal && !isScientific && !isNegative {
-		t = naturalNumber
-	} else if !isDecimal && !isScientific {
-		t = integer
-	} else if !isScientific {
+	if l.pos < len(l.input) && l.input[l.pos] == '.' {
+		l.pos = l.scanDigits(l.pos+1, isNumeric)
 		t = fixedNumber
 	}
+	if l.pos < len(l.input) && (l.input[l.pos] == 'e' || l.input[l.pos] == 'E') {
+		pos := l.pos + 1
+		if pos < len(l.input) && (l.input[pos] == '+' || l.input[pos] == '-') {
+			pos++
+		}
+		if pos < len(l.input) && isNumeric(rune(l.input[pos])) {
+			l.pos = l.scanDigits(pos, isNumeric)
+			t = floatingPointNumber
+		}
+	}
 
-	return item{t, buf.String()}
+	return item{Token: t, Value: l.input[start:l.pos]}
+}
+
+// scanDigits returns the position of the first character from pos which is
+// not a digit.
+func (l *lexer) scanDigits(pos int, digit func(rune) bool) int {
+	for pos < len(l.input) && digit(rune(l.input[pos])) {
+		pos++
+	}
+	return pos
+}
+
+// scanBinaryString scans a hexadecimal, X'1F', or bit, b'0101', string
+// literal following the X or b read at start. A hexadecimal literal must have
+// an even number of digits.
+func (l *lexer) scanBinaryString(start int) item {
+	t, digit := hexNumber, isHexDigit
+	if l.input[start] == 'b' || l.input[start] == 'B' {
+		t, digit = bitNumber, isBitDigit
+	}
+
+	end := strings.IndexByte(l.input[start+2:], '\'')
+	if end < 0 {
+		l.pos = len(l.input)
+		l.reason = ErrUnterminated
+		return item{Token: illegal, Value: l.input[start:]}
+	}
+	digits := l.input[start+2 : start+2+end]
+	l.pos = start + 3 + end
+	if l.scanDigits(start+2, digit) != start+2+end || (t == hexNumber && len(digits)%2 != 0) {
+		return item{Token: illegal, Value: l.input[start:l.pos]}
+	}
+	return item{Token: t, Value: l.input[start:l.pos]}
+}
+
+// scanIntroduced scans a literal following a character set introducer read at
+// start, such as _utf8mb4'text' or _binary 0x1F. It reports false when the
+// word at start is not an introducer of a literal.
+func (l *lexer) scanIntroduced(start int) (item, bool) {
+	end := l.scanWord(start)
+	if !charsets[strings.ToLower(l.input[start+1:end])] {
+		return item{}, false
+	}
+
+	l.pos = end
+	for l.pos < len(l.input) && isWhitespace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+	var it item
+	switch ch := l.read(); {
+	case ch == '\'' || ch == '"':
+		l.unread()
+		if it = l.scanQuoted(); it.Token == quotedString {
+			it.Token = charsetString
+		}
+	case (ch == 'x' || ch == 'X' || ch == 'b' || ch == 'B') && l.peek() == '\'':
+		it = l.scanBinaryString(l.pos - 1)
+	case l.prefixed(l.pos-1) != illegal:
+		l.unread()
+		it = l.scanNumber()
+	default:
+		return item{}, false
+	}
+	it.Value = l.input[start:l.pos]
+	return it, true
 }
 
 // scanWhitespace returns a whitespace token WS and a contiguous sequence of
 // whitespace characters
 func (l *lexer) scanWhitespace() item {
-	var buf bytes.Buffer
-	buf.WriteRune(l.read())
+	start := l.pos
+	l.read()
 
 	for {
 		if ch := l.read(); ch == eof {
@@ -216,181 +573,146 @@ func (l *lexer) scanWhitespace() item {
 		} else if !isWhitespace(ch) {
 			l.unread()
 			break
-		} else {
-			buf.WriteRune(ch)
 		}
 	}
 
-	return item{WS, buf.String()}
+	return item{Token: WS, Value: l.input[start:l.pos]}
 }
 
-// scanIdent fetches the next token from a lexing stream and returns the matched
-// token type, or a token type of IDENTIFIER if it is not one of the SQL Keywords
-// Any quoted identifier must begin and end with the same type of quote.
+// scanQuoted scans a string quoted with ' or ", or an identifier quoted with
+// backticks. The quote is escaped inside by doubling it and, in strings, by a
+// backslash. An unterminated string or identifier is illegal.
 func (l *lexer) scanQuoted() item {
-	var buf bytes.Buffer
-	var first, last rune
-	first = l.read()
-	buf.WriteRune(first)
+	start := l.pos
+	quote := l.read()
 
 	for {
-		// Stop at EOF
-		if ch := l.read(); ch == eof {
-			break
+		switch ch := l.read(); {
+		case ch == eof:
+			l.reason = ErrUnterminated
+			return item{Token: illegal, Value: l.input[start:l.pos]}
+		case ch == '\\' && quote != '`':
+			l.read()
+		case ch == quote:
+			if l.peek() != quote {
+				tok := quotedString
+				if quote == '`' {
+					tok = identifier
+				}
+				return item{Token: tok, Value: l.input[start:l.pos]}
+			}
+			l.read()
+		}
+	}
+}
 
-			// If we're quoted atop at first matching unescaped quote
-		} else if ch == first {
-			buf.WriteRune(ch)
-			check := last
-			last = ch
-			next := l.read()
-			l.unread()
+// scanKeyword scans a word and returns its keyword token, or an identifier
+// token when it is not the start of a keyword. A keyword of several words
+// matches when its words are separated by any whitespace and comments, the
+// longest keyword matching the input is returned.
+func (l *lexer) scanKeyword() item {
+	start := l.pos
+	end := l.scanWord(start)
+	kws := lookupKeyword(l.input[start:end])
+	if len(kws) == 0 {
+		l.pos = end
+		return item{Token: identifier, Value: l.input[start:end]}
+	}
 
-			// If Peek is EOF we're already at the end of the string and past
-			// quote escaping checking
-			if next == eof {
-				break
-			}
+	t := identifier
+	longest := end
+	for _, kw := range kws {
+		if e, ok := l.matchWords(end, kw.words[1:]); ok && (t == identifier || e > longest) {
+			t, longest = kw.t, e
+		}
+	}
 
-			// If we're at a quote
-			if ch == '`' || ((check != '\\' && check != ch) && next != ch) {
-				break
-			}
+	l.pos = longest
+	return item{Token: t, Value: l.input[start:longest]}
+}
 
-			// We're still inside the token, keep adding to buffer
-		} else {
-			last = ch
-			buf.WriteRune(ch)
+// lookupKeyword returns the keywords starting with word, matched regardless of
+// case. The word is upper cased into a fixed size buffer so looking it up does
+// not allocate.
+func lookupKeyword(word string) []keywordDef {
+	var buf [maxKeywordLen]byte
+	if len(word) > len(buf) {
+		return nil
+	}
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
 		}
+		buf[i] = c
 	}
+	return keywords[string(buf[:len(word)])]
+}
 
-	if first != last {
-		return item{illegal, buf.String()}
+// matchWords reports whether the input following pos continues with words,
+// each preceded by whitespace or comments, and the position after the last
+// word.
+func (l *lexer) matchWords(pos int, words []string) (int, bool) {
+	for _, w := range words {
+		next := l.skipSeparators(pos)
+		if next == pos {
+			return 0, false
+		}
+		end := l.scanWord(next)
+		if !strings.EqualFold(l.input[next:end], w) {
+			return 0, false
+		}
+		pos = end
 	}
+	return pos, true
+}
 
-	var tok = identifier
-	if first != '`' {
-		tok = quotedString
+// scanWord returns the position of the end of the identifier word starting at
+// pos. Like MySQL, identifiers may hold $ and any character beyond ASCII.
+func (l *lexer) scanWord(pos int) int {
+	for pos < len(l.input) && (isAlphanum(rune(l.input[pos])) || l.input[pos] == '$' || l.input[pos] >= utf8.RuneSelf) {
+		pos++
 	}
+	return pos
+}
 
-	return item{tok, buf.String()}
+// lineComment reports whether a -- comment starts at pos. The dashes must be
+// followed by whitespace, as in MySQL.
+func (l *lexer) lineComment(pos int) bool {
+	return strings.HasPrefix(l.input[pos:], "--") &&
+		(pos+2 == len(l.input) || isWhitespace(rune(l.input[pos+2])))
 }
 
-func (l *lexer) scanKeyword() item {
-	if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), selectStmt) {
-		l.pos += len(selectStmt)
-		return item{selectToken, selectStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), insertStmt) {
-		return item{insertToken, insertStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), fromStmt) {
-		return item{fromToken, fromStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), partitionStmt) {
-		return item{partitionToken, partitionStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), asStmt) {
-		return item{asToken, asStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), straightJoinStmt) {
-		return item{straightJoinToken, straightJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), crossJoinStmt) {
-		return item{crossJoinToken, crossJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), innerJoinStmt) {
-		return item{innerJoinToken, innerJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), ojStmt) {
-		return item{ojToken, ojStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), naturalJoinStmt) {
-		return item{naturalJoinToken, naturalJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), naturalLeftJoinStmt) {
-		return item{naturalLeftJoinToken, naturalLeftJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), naturalLeftOuterJoinStmt) {
-		return item{naturalLeftOuterJoinToken, naturalLeftOuterJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), naturalRightJoinStmt) {
-		return item{naturalRightJoinToken, naturalRightJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), naturalRightOuterJoinStmt) {
-		return item{naturalRightOuterJoinToken, naturalRightOuterJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), leftJoinStmt) {
-		return item{leftJoinToken, leftJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), leftOuterJoinStmt) {
-		return item{leftOuterJoinToken, leftOuterJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), rightJoinStmt) {
-		return item{rightJoinToken, rightJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), rightOuterJoinStmt) {
-		return item{rightOuterJoinToken, rightOuterJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), useIndexStmt) {
-		return item{useIndexToken, useIndexStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), useKeyStmt) {
-		return item{useKeyToken, useKeyStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), ignoreIndexStmt) {
-		return item{ignoreIndexToken, ignoreIndexStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), ignoreKeyStmt) {
-		return item{ignoreKeyToken, ignoreKeyStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), forceIndexStmt) {
-		return item{forceIndexToken, forceIndexStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), forceKeyStmt) {
-		return item{forceKeyToken, forceKeyStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), forJoinStmt) {
-		return item{forJoinToken, forJoinStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), forOrderByStmt) {
-		return item{forOrderByToken, forOrderByStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), forGroupByStmt) {
-		return item{forGroupByToken, forGroupByStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), whereStmt) {
-		return item{whereToken, whereStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), valuesStmt) {
-		return item{valuesToken, valuesStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), setStmt) {
-		return item{setToken, setStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), defaultStmt) {
-		return item{defaultToken, defaultStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), allStmt) {
-		return item{allToken, allStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), distinctStmt) {
-		return item{distinctToken, distinctStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), highPriorityStmt) {
-		return item{highPriorityToken, highPriorityStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), lowPriorityStmt) {
-		return item{lowPriorityToken, lowPriorityStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), delayedStmt) {
-		return item{delayedToken, delayedStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), maxStatementTimeStmt) {
-		return item{maxStatementTimeToken, maxStatementTimeStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), sqlSmallResultStmt) {
-		return item{sqlSmallResultToken, sqlSmallResultStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), sqlBigResultStmt) {
-		return item{sqlBigResultToken, sqlBigResultStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), sqlBufferResultStmt) {
-		return item{sqlBufferResultToken, sqlBufferResultStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), sqlCacheStmt) {
-		return item{sqlCacheToken, sqlCacheStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), sqlNoCacheStmt) {
-		return item{sqlNoCacheToken, sqlNoCacheStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), sqlCalcFoundRowsStmt) {
-		return item{sqlCalcFoundRowsToken, sqlCalcFoundRowsStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), onStmt) {
-		return item{onToken, onStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), usingStmt) {
-		return item{usingToken, usingStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), orderByStmt) {
-		return item{orderByToken, orderByStmt}
-	} else if strings.HasPrefix(strings.ToUpper(l.input[l.pos:]), groupByStmt) {
-		return item{groupByToken, groupByStmt}
-	}
-
-	var buf bytes.Buffer
-	for {
-		ch := l.read()
-		if !isAlphanum(ch) {
-			l.unread()
-			break
+// skipSeparators returns the position of the first character following pos
+// which is neither whitespace nor part of a comment.
+func (l *lexer) skipSeparators(pos int) int {
+	for pos < len(l.input) {
+		switch {
+		case isWhitespace(rune(l.input[pos])):
+			pos++
+		case l.input[pos] == '#' || l.lineComment(pos):
+			if i := strings.IndexByte(l.input[pos:], '\n'); i >= 0 {
+				pos += i + 1
+			} else {
+				pos = len(l.input)
+			}
+		case strings.HasPrefix(l.input[pos:], "/*"):
+			i := strings.Index(l.input[pos+2:], "*/")
+			if i < 0 {
+				return pos
+			}
+			pos += i + 4
+		default:
+			return pos
 		}
-		buf.WriteRune(ch)
 	}
-
-	return item{identifier, buf.String()}
+	return pos
 }
 
 // isWhitespace is a helper function to identify whitespace characters within
 // a lexing stream.
 func isWhitespace(ch rune) bool {
-	return ch == ' ' || ch == '\n' || ch == '\t'
+	return ch == ' ' || ch == '\n' || ch == '\t' || ch == '\r' || ch == '\f' || ch == '\v'
 }
 
 // isLetter is a helper function to identify alphabetic characters within
@@ -405,6 +727,16 @@ func isNumeric(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
 
+// isHexDigit reports whether ch is a hexadecimal digit.
+func isHexDigit(ch rune) bool {
+	return isNumeric(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+// isBitDigit reports whether ch is a binary digit.
+func isBitDigit(ch rune) bool {
+	return ch == '0' || ch == '1'
+}
+
 // isAlphanum is a helper function that composes numeric and alphabetic check functions
 // along with accepting an underscore for the purposes of lexing identifiers
 // within a lexing stream.
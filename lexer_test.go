@@ -1,6 +1,9 @@
 package rdb
 
 import (
+	"errors"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -15,6 +18,11 @@ func TestIsWhitespace(t *testing.T) {
 	if !isWhitespace(rune('\t')) {
 		t.Errorf("Tab failed whitespace test.")
 	}
+	for _, ch := range "\r\f\v" {
+		if !isWhitespace(ch) {
+			t.Errorf("%q failed whitespace test.", ch)
+		}
+	}
 
 	if isWhitespace(rune('z')) {
 		t.Errorf("Non-whitespace character passed whitespace test.")
@@ -325,7 +333,7 @@ func TestScanHandlesEscapedBslashSquote(t *testing.T) {
 }
 
 func TestScanHandlesEscapedBslashDquote(t *testing.T) {
-	str := `"iamnota\\"keyword"`
+	str := `"iamnota\"keyword"`
 	s := lex(str)
 	item := s.scan()
 	if item.Token != quotedString {
@@ -436,3 +444,441 @@ func TestKeywordTokens(t *testing.T) {
 		}
 	}
 }
+
+func TestNextLexesStatement(t *testing.T) {
+	str := "SELECT u.id, `name` FROM users u WHERE id = 12"
+	expected := []item{
+		{Token: selectToken, Value: "SELECT", Pos: 0},
+		{Token: WS, Value: " ", Pos: 6},
+		{Token: identifier, Value: "u", Pos: 7},
+		{Token: period, Value: ".", Pos: 8},
+		{Token: identifier, Value: "id", Pos: 9},
+		{Token: comma, Value: ",", Pos: 11},
+		{Token: WS, Value: " ", Pos: 12},
+		{Token: identifier, Value: "`name`", Pos: 13},
+		{Token: WS, Value: " ", Pos: 19},
+		{Token: fromToken, Value: "FROM", Pos: 20},
+		{Token: WS, Value: " ", Pos: 24},
+		{Token: identifier, Value: "users", Pos: 25},
+		{Token: WS, Value: " ", Pos: 30},
+		{Token: identifier, Value: "u", Pos: 31},
+		{Token: WS, Value: " ", Pos: 32},
+		{Token: whereToken, Value: "WHERE", Pos: 33},
+		{Token: WS, Value: " ", Pos: 38},
+		{Token: identifier, Value: "id", Pos: 39},
+		{Token: WS, Value: " ", Pos: 41},
+		{Token: equals, Value: "=", Pos: 42},
+		{Token: WS, Value: " ", Pos: 43},
+		{Token: naturalNumber, Value: "12", Pos: 44},
+	}
+
+	l := lex(str)
+	for i, e := range expected {
+		e.Line, e.Col = 1, e.Pos+1
+		if it := l.Next(); it != e {
+			t.Errorf("Expected token %d to be %+v, got %+v", i, e, it)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if it := l.Next(); it.Token != EOF || it.Pos != len(str) {
+			t.Errorf("Expected EOF at %d once the query is exhausted, got %+v", len(str), it)
+		}
+	}
+
+	// CRLF line endings separate tokens as any whitespace
+	str = "SELECT id\r\nFROM users\r\n\tWHERE id = 12 -- last\r\n"
+	expected = []item{
+		{Token: selectToken, Value: "SELECT", Pos: 0, Line: 1, Col: 1},
+		{Token: WS, Value: " ", Pos: 6, Line: 1, Col: 7},
+		{Token: identifier, Value: "id", Pos: 7, Line: 1, Col: 8},
+		{Token: WS, Value: "\r\n", Pos: 9, Line: 1, Col: 10},
+		{Token: fromToken, Value: "FROM", Pos: 11, Line: 2, Col: 1},
+		{Token: WS, Value: " ", Pos: 15, Line: 2, Col: 5},
+		{Token: identifier, Value: "users", Pos: 16, Line: 2, Col: 6},
+		{Token: WS, Value: "\r\n\t", Pos: 21, Line: 2, Col: 11},
+		{Token: whereToken, Value: "WHERE", Pos: 24, Line: 3, Col: 2},
+	}
+	l = lex(str)
+	for i, e := range expected {
+		if it := l.Next(); it != e {
+			t.Errorf("Expected CRLF token %d to be %+v, got %+v", i, e, it)
+		}
+	}
+	for it := l.Next(); it.Token != EOF; it = l.Next() {
+		if it.Token == illegal {
+			t.Errorf("Not expecting an illegal token lexing CRLF line endings, got %+v", it)
+		}
+	}
+}
+
+func TestNextLexesInsert(t *testing.T) {
+	str := "insert INTO t (a,b) values (1,'x')"
+	var got []string
+	for _, it := range lex(str).all() {
+		if it.Token != WS {
+			got = append(got, it.Value)
+		}
+	}
+
+	expected := []string{"insert", "INTO", "t", "(", "a", ",", "b", ")", "values", "(", "1", ",", "'x'", ")"}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected tokens:\n%q\nGot:\n%q", expected, got)
+	}
+}
+
+func TestScanKeywordRequiresWordBoundary(t *testing.T) {
+	for _, str := range []string{"assets", "settings", "onion", "Selected", "from_date", "LEFT", "FOR"} {
+		item := lex(str + "(1)").scan()
+		if item.Token != identifier || item.Value != str {
+			t.Errorf("Expected identifier %s, got %d (%s)", str, item.Token, item.Value)
+		}
+	}
+}
+
+func TestScanKeywordLongestMatch(t *testing.T) {
+	tests := []struct {
+		str   string
+		tok   token
+		value string
+	}{
+		{"natural left outer join t", naturalLeftOuterJoinToken, "natural left outer join"},
+		{"NATURAL  LEFT\n\tJOIN t", naturalLeftJoinToken, "NATURAL  LEFT\n\tJOIN"},
+		{"LEFT /* all of them */ OUTER -- outer\nJOIN t", leftOuterJoinToken, "LEFT /* all of them */ OUTER -- outer\nJOIN"},
+		{"FOR # hint\nORDER BY", forOrderByToken, "FOR # hint\nORDER BY"},
+		{"ORDER BYx", identifier, "ORDER"},
+		{"Group By id", groupByToken, "Group By"},
+		{"on duplicate key update a = 1", onDuplicateKeyUpdateToken, "on duplicate key update"},
+		{"ON DUPLICATE a", onToken, "ON"},
+		{"IGNORE INTO t", ignoreToken, "IGNORE"},
+		{"IGNORE KEY (i)", ignoreKeyToken, "IGNORE KEY"},
+	}
+
+	for _, tt := range tests {
+		l := lex(tt.str)
+		item := l.scan()
+		if item.Token != tt.tok || item.Value != tt.value {
+			t.Errorf("Expected token %d (%q) lexing %q, got %d (%q)", tt.tok, tt.value, tt.str, item.Token, item.Value)
+		}
+		if l.pos != len(tt.value) {
+			t.Errorf("Expected position %d after %q, got %d", len(tt.value), tt.value, l.pos)
+		}
+	}
+}
+
+// largeQuery returns a SELECT statement with n expressions in its select list
+// and n conditions in its WHERE clause.
+func largeQuery(n int) string {
+	var b strings.Builder
+	b.WriteString("SELECT DISTINCT ")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("u.`column_name`, COUNT(*) AS total, 'a ''quoted'' value', 3.14159")
+	}
+	b.WriteString(" FROM app.users AS u NATURAL LEFT OUTER JOIN orders o WHERE ")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(" OR ")
+		}
+		b.WriteString("o.assets = 12\n\t")
+	}
+	b.WriteString("ORDER BY u.id")
+	return b.String()
+}
+
+// lexAll pulls every token of query from a lexer.
+func lexAll(query string) int {
+	n := 0
+	l := lex(query)
+	for l.Next().Token != EOF {
+		n++
+	}
+	return n
+}
+
+func TestLexDoesNotAllocatePerToken(t *testing.T) {
+	small, large := largeQuery(10), largeQuery(1000)
+	if s, l := testing.AllocsPerRun(10, func() { lexAll(small) }), testing.AllocsPerRun(10, func() { lexAll(large) }); l != s {
+		t.Errorf("Expected lexing to allocate the same regardless of query size, got %v and %v", s, l)
+	}
+}
+
+func BenchmarkLex(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		query := largeQuery(n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(query)))
+			for i := 0; i < b.N; i++ {
+				lexAll(query)
+			}
+		})
+	}
+}
+
+func TestScanOperators(t *testing.T) {
+	tests := []struct {
+		str string
+		tok token
+	}{
+		{"<", lessThan}, {">", greaterThan}, {"<=", lessOrEqual}, {">=", greaterOrEqual},
+		{"<>", notEqual}, {"!=", notEqual}, {"<=>", nullSafeEqual}, {"+", plus},
+		{"/", slash}, {"%", percent}, {"||", logicalOr}, {"&&", logicalAnd},
+		{"!", bang}, {"|", pipe}, {"&", ampersand}, {"^", caret}, {"~", tilde},
+		{"<<", shiftLeft}, {">>", shiftRight}, {":=", assign}, {"->", jsonExtract},
+		{"->>", jsonUnquote}, {";", semicolon}, {"?", placeholder}, {":name", namedParam},
+		{"@user_var", userVariable}, {"@'my var'", userVariable}, {"@`v`", userVariable},
+		{"@@sql_mode", systemVariable}, {"@@GLOBAL.max_connections", systemVariable},
+	}
+
+	for _, tt := range tests {
+		item := lex(tt.str).scan()
+		if item.Token != tt.tok || item.Value != tt.str {
+			t.Errorf("Expected token %d (%s), got %d (%s)", tt.tok, tt.str, item.Token, item.Value)
+		}
+	}
+}
+
+func TestScanSignsByContext(t *testing.T) {
+	str := "a-1 >= -2+b*+3e-2 AND x <=> @v"
+	var got []string
+	for _, it := range lex(str).all() {
+		if it.Token != WS {
+			got = append(got, it.Value)
+		}
+	}
+
+	expected := []string{"a", "-", "1", ">=", "-2", "+", "b", "*", "+3e-2", "AND", "x", "<=>", "@v"}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected tokens:\n%q\nGot:\n%q", expected, got)
+	}
+}
+
+func TestScanLiterals(t *testing.T) {
+	tests := []struct {
+		str   string
+		tok   token
+		value string
+	}{
+		{"12+3", naturalNumber, "12"},
+		{"-1.5e+3-2", floatingPointNumber, "-1.5e+3"},
+		{"2e", naturalNumber, "2"},
+		{"3.e-x", fixedNumber, "3."},
+		{".5)", fixedNumber, ".5"},
+		{".5e3,", floatingPointNumber, ".5e3"},
+		{"-.5E-2", floatingPointNumber, "-.5E-2"},
+		{".e1", period, "."},
+		{"-.x", minus, "-"},
+		{"0x1F AND", hexNumber, "0x1F"},
+		{"0X1F", naturalNumber, "0"},
+		{"x'1f'", hexNumber, "x'1f'"},
+		{"X'1'", illegal, "X'1'"},
+		{"0b0101", bitNumber, "0b0101"},
+		{"B'012'", illegal, "B'012'"},
+		{"b'0101", illegal, "b'0101"},
+		{"N'text' x", charsetString, "N'text'"},
+		{"_utf8mb4'text'", charsetString, "_utf8mb4'text'"},
+		{"_LATIN1 \"a\\\"b\"", charsetString, "_LATIN1 \"a\\\"b\""},
+		{"_binary X'0F'", hexNumber, "_binary X'0F'"},
+		{"_binary 0b1", bitNumber, "_binary 0b1"},
+		{"_utf8 AS", identifier, "_utf8"},
+		{"_column'alias'", identifier, "_column"},
+		{"true", trueToken, "true"},
+		{"FALSE", falseToken, "FALSE"},
+	}
+
+	for _, tt := range tests {
+		l := lex(tt.str)
+		item := l.scan()
+		if item.Token != tt.tok || item.Value != tt.value {
+			t.Errorf("Expected token %d (%q) lexing %q, got %d (%q)", tt.tok, tt.value, tt.str, item.Token, item.Value)
+		}
+	}
+
+	// A period after an operand qualifies a name or is illegal
+	var got []string
+	for _, it := range lex("t.5, (.5).5, a-.5").all() {
+		if it.Token != WS {
+			got = append(got, it.Value)
+		}
+	}
+	expected := []string{"t", ".", "5", ",", "(", ".5", ")", ".", "5", ",", "a", "-", ".5"}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected tokens:\n%q\nGot:\n%q", expected, got)
+	}
+
+	// A sign is not part of a hexadecimal or bit number
+	got = nil
+	for _, it := range lex("-0x1F, +0b1").all() {
+		if it.Token != WS {
+			got = append(got, it.Value)
+		}
+	}
+	expected = []string{"-", "0x1F", ",", "+", "0b1"}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected tokens:\n%q\nGot:\n%q", expected, got)
+	}
+}
+
+func TestDecodeLiteral(t *testing.T) {
+	tests := []struct {
+		str      string
+		expected interface{}
+	}{
+		{"42", int64(42)},
+		{"-42", int64(-42)},
+		{"+7", int64(7)},
+		{"18446744073709551615", uint64(18446744073709551615)},
+		{"1.25", 1.25},
+		{"-2e3", -2000.0},
+		{".25", 0.25},
+		{"-.5e1", -5.0},
+		{`'it''s \'quoted\'\n\t\\ \%\_ \x'`, "it's 'quoted'\n\t\\ \\%\\_ x"},
+		{`"say ""hi"""`, `say "hi"`},
+		{"N'nchar'", "nchar"},
+		{"_utf8mb4 'caf\u00e9'", "caf\u00e9"},
+		{"X'0aFF'", []byte{0x0a, 0xff}},
+		{"0x1", []byte{0x01}},
+		{"_binary 0x102", []byte{0x01, 0x02}},
+		{"b'1'", []byte{0x01}},
+		{"0b100000001", []byte{0x01, 0x01}},
+		{"_binary b'11111111'", []byte{0xff}},
+		{"TRUE", true},
+		{"false", false},
+		{"NULL", nil},
+	}
+
+	for _, tt := range tests {
+		item := lex(tt.str).scan()
+		got, err := decodeLiteral(item.Token, item.Value)
+		if err != nil {
+			t.Errorf("Not expecting error decoding %s but got: %s", tt.str, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Expected %s to decode to %#v, got %#v", tt.str, tt.expected, got)
+		}
+	}
+
+	for _, str := range []string{"99999999999999999999", "DEFAULT"} {
+		item := lex(str).scan()
+		if _, err := decodeLiteral(item.Token, item.Value); err == nil {
+			t.Errorf("Expected error decoding %s", str)
+		}
+	}
+}
+
+func TestScanComments(t *testing.T) {
+	tests := []struct {
+		str   string
+		tok   token
+		value string
+	}{
+		{"-- a comment\nSELECT", comment, "-- a comment\n"},
+		{"--\tcomment", comment, "--\tcomment"},
+		{"# mysqldump\n", comment, "# mysqldump\n"},
+		{"/* multi\nline */ SELECT", comment, "/* multi\nline */"},
+		{"/*!40101 SET NAMES utf8 */;", versionedComment, "/*!40101 SET NAMES utf8 */"},
+		{"/*+ BKA(t1) NO_BKA(t2) */ *", optimizerHint, "/*+ BKA(t1) NO_BKA(t2) */"},
+		{"/* unterminated", illegal, "/* unterminated"},
+		{"--1", minus, "-"},
+	}
+
+	for _, tt := range tests {
+		item := lex(tt.str).scan()
+		if item.Token != tt.tok || item.Value != tt.value {
+			t.Errorf("Expected token %d (%q), got %d (%q)", tt.tok, tt.value, item.Token, item.Value)
+		}
+	}
+}
+
+func TestCommentsDoNotChangeSigns(t *testing.T) {
+	var got []token
+	for _, it := range lex("SELECT /*+ hint */ -1 # one\n").all() {
+		got = append(got, it.Token)
+	}
+
+	expected := []token{selectToken, WS, optimizerHint, WS, integer, WS, comment}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected tokens %v, got %v", expected, got)
+	}
+}
+
+func TestItemLineAndColumn(t *testing.T) {
+	str := "SELECT\n\tnom, 'a\nb' AS café,\n\tx"
+	expected := map[string][2]int{
+		"SELECT": {1, 1}, "nom": {2, 2}, "'a\nb'": {2, 7}, "AS": {3, 4}, "café": {3, 7}, "x": {4, 2},
+	}
+
+	for _, it := range lex(str).all() {
+		if pos, ok := expected[it.Value]; ok && (it.Line != pos[0] || it.Col != pos[1]) {
+			t.Errorf("Expected %q at line %d, column %d, got line %d, column %d", it.Value, pos[0], pos[1], it.Line, it.Col)
+		}
+	}
+}
+
+func TestLexError(t *testing.T) {
+	l := lex("SELECT id\n\tFROM t WHERE a = 'oops")
+	l.all()
+	err := l.Err()
+	if err == nil {
+		t.Fatalf("Expected error lexing an unterminated string")
+	}
+	if !errors.Is(err, ErrUnterminated) {
+		t.Errorf("Expected unterminated token error, got %s", err.Error())
+	}
+
+	m := "Unterminated token \"'oops\" at line 2, column 19\n\t\tFROM t WHERE a = 'oops\n\t\t                 ^"
+	if err.Error() != m {
+		t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, err.Error())
+	}
+
+	l = lex("SELECT @ FROM t WHERE `a = 1")
+	l.all()
+	var lexErr *LexError
+	if !errors.As(l.Err(), &lexErr) || len(lexErr.Errors) != 2 {
+		t.Fatalf("Expected a LexError with two problems, got %v", l.Err())
+	}
+	if e := lexErr.Errors[0]; e.Kind != ErrIllegalToken || e.Value != "@" || e.Pos != 7 {
+		t.Errorf("Expected illegal token @ at 7, got %+v", e)
+	}
+	if e := lexErr.Errors[1]; e.Kind != ErrUnterminated || e.Value != "`a = 1" || e.Col != 23 {
+		t.Errorf("Expected unterminated token `a = 1 at column 23, got %+v", e)
+	}
+
+	if l := lex("SELECT 1"); len(l.all()) != 3 || l.Err() != nil {
+		t.Errorf("Not expecting error lexing a legal query but got: %v", l.Err())
+	}
+}
+
+func TestLexNulCharacter(t *testing.T) {
+	// A NUL character does not end the input
+	l := lex("SELECT a FROM t WHERE a = 1\x00 DROP TABLE t")
+	items := l.all()
+	if it := items[len(items)-1]; it.Token != identifier || it.Value != "t" {
+		t.Errorf("Expected the query to be lexed past the NUL character, got %+v", it)
+	}
+	var lexErr *LexError
+	if !errors.As(l.Err(), &lexErr) || len(lexErr.Errors) != 1 {
+		t.Fatalf("Expected a LexError with one problem, got %v", l.Err())
+	}
+	if e := lexErr.Errors[0]; e.Kind != ErrIllegalToken || e.Value != "\x00" || e.Pos != 27 {
+		t.Errorf("Expected illegal token NUL at 27, got %+v", e)
+	}
+	if _, err := Parse("SELECT a FROM t WHERE a = 1\x00 DROP TABLE t"); !errors.Is(err, ErrIllegalToken) {
+		t.Errorf("Expected an illegal token error parsing a query with a NUL character, got %v", err)
+	}
+
+	// Inside strings and quoted identifiers it is as any character
+	for _, str := range []string{"'a\x00b'", "`a\x00b`"} {
+		l = lex(str)
+		if it := l.scan(); it.Token == illegal || it.Value != str {
+			t.Errorf("Expected: %q Got: %+v", str, it)
+		}
+		if it := l.scan(); it.Token != EOF {
+			t.Errorf("Expected EOF after %q, got %+v", str, it)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package rdb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeLiteral returns the value of a literal token as written in a query:
+//  - numbers are int64, or uint64 when too large for an int64, and float64
+//    when they have a fraction or an exponent.
+//  - strings, with or without a character set introducer, are string with
+//    their escape sequences decoded.
+//  - hexadecimal and bit literals are []byte, as MySQL treats them as binary
+//    strings.
+//  - TRUE and FALSE are bool and NULL is nil.
+func decodeLiteral(t token, raw string) (interface{}, error) {
+	switch t {
+	case naturalNumber, integer:
+		if n, err := strconv.ParseInt(strings.TrimPrefix(raw, "+"), 10, 64); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseUint(strings.TrimPrefix(raw, "+"), 10, 64); err == nil {
+			return n, nil
+		}
+		return nil, fmt.Errorf("Literal %s is out of range", raw)
+
+	case fixedNumber, floatingPointNumber:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Literal %s is out of range", raw)
+		}
+		return f, nil
+
+	case quotedString, charsetString:
+		return unquote(withoutIntroducer(raw)), nil
+
+	case hexNumber:
+		digits := binaryDigits(withoutIntroducer(raw))
+		if len(digits)%2 != 0 {
+			digits = "0" + digits
+		}
+		return hex.DecodeString(digits)
+
+	case bitNumber:
+		digits := binaryDigits(withoutIntroducer(raw))
+		b := make([]byte, (len(digits)+7)/8)
+		for i := 0; i < len(digits); i++ {
+			if digits[len(digits)-1-i] == '1' {
+				b[len(b)-1-i/8] |= 1 << uint(i%8)
+			}
+		}
+		return b, nil
+
+	case trueToken:
+		return true, nil
+	case falseToken:
+		return false, nil
+	case nullToken:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%s is not a literal value", raw)
+}
+
+// withoutIntroducer returns a literal without its character set introducer,
+// _charset or N.
+func withoutIntroducer(raw string) string {
+	switch {
+	case raw[0] == 'n' || raw[0] == 'N':
+		return raw[1:]
+	case raw[0] == '_':
+		i := 1
+		for i < len(raw) && isAlphanum(rune(raw[i])) {
+			i++
+		}
+		return strings.TrimLeftFunc(raw[i:], isWhitespace)
+	}
+	return raw
+}
+
+// binaryDigits returns the digits of a hexadecimal or bit literal, X'1F',
+// 0x1F, b'0101' or 0b0101.
+func binaryDigits(raw string) string {
+	if raw[0] == '0' {
+		return raw[2:]
+	}
+	return raw[2 : len(raw)-1]
+}
@@ -0,0 +1,216 @@
+package rdb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ErrNotRegistered is returned when a model has not been registered with the
+// Registry it is looked up in.
+var ErrNotRegistered = errors.New("Model has not been registered")
+
+// Model is a read-only description of a registered model struct. A Model is
+// a snapshot, registering the model again does not change descriptors that
+// were already returned.
+type Model struct {
+	t *table
+}
+
+// Type returns the struct type of the model.
+func (m Model) Type() reflect.Type {
+	return m.t.model
+}
+
+// Name returns the package qualified name of the model type.
+func (m Model) Name() string {
+	return typeName(m.t.model)
+}
+
+// Table returns the database table the model maps to.
+func (m Model) Table() Table {
+	return Table{db: m.t.dbName, name: m.t.name}
+}
+
+// Columns returns the columns of the model in struct field order.
+func (m Model) Columns() []Column {
+	cols := make([]Column, len(m.t.cols))
+	for i, c := range m.t.cols {
+		cols[i] = Column{c}
+	}
+	return cols
+}
+
+// PrimaryKey returns the primary key columns of the model in key order. The
+// slice holds several columns for a composite key and is empty when no column
+// is flagged pk.
+func (m Model) PrimaryKey() []Column {
+	cols := make([]Column, len(m.t.pk))
+	for i, c := range m.t.keyColumns() {
+		cols[i] = Column{c}
+	}
+	return cols
+}
+
+// Table is a read-only description of a database table a model maps to.
+type Table struct {
+	db   string
+	name string
+}
+
+// Database returns the name of the database the table belongs to.
+func (t Table) Database() string {
+	return t.db
+}
+
+// Name returns the name of the table.
+func (t Table) Name() string {
+	return t.name
+}
+
+// String returns the database qualified table name, db_name.tbl_name.
+func (t Table) String() string {
+	return t.db + "." + t.name
+}
+
+// Column is a read-only description of a model field mapped to a column.
+type Column struct {
+	c column
+}
+
+// Field returns the name of the model field the column is mapped to.
+func (c Column) Field() string {
+	return c.c.fieldName
+}
+
+// Name returns the name of the column in the database table.
+func (c Column) Name() string {
+	return c.c.colName
+}
+
+// Kind returns the kind of the model field the column is mapped to.
+func (c Column) Kind() reflect.Kind {
+	return c.c.colType
+}
+
+// PrimaryKey reports whether the column is part of the primary key.
+func (c Column) PrimaryKey() bool {
+	return c.c.pk
+}
+
+// KeyPosition returns the position of the column in the primary key, starting
+// at 1, or 0 if the column is not part of the primary key.
+func (c Column) KeyPosition() int {
+	return c.c.pkOrder
+}
+
+// AutoIncrement reports whether the column is an auto-increment column.
+func (c Column) AutoIncrement() bool {
+	return c.c.ai
+}
+
+// ForeignKey reports whether the column declares a foreign-key map.
+func (c Column) ForeignKey() bool {
+	return c.c.fk
+}
+
+// Relation returns the ColName.Model.Field foreign-key map of the column, or
+// an empty string if the column is not a foreign key.
+func (c Column) Relation() string {
+	return c.c.colRelation
+}
+
+// Nullable reports whether the column accepts null values.
+func (c Column) Nullable() bool {
+	return c.c.null
+}
+
+// table returns the registered table definition of a model given as a struct
+// value or pointer to a struct.
+func (reg *Registry) table(model interface{}) (*table, error) {
+	r := modelType(model)
+	if r == nil {
+		return nil, fmt.Errorf("Models must be struct types. Called on %T", model)
+	}
+
+	t, ok := reg.lookup(r)
+	if !ok {
+		return nil, fmt.Errorf(`%w: "%s"`, ErrNotRegistered, typeName(r))
+	}
+
+	return t, nil
+}
+
+// Model returns the description of a registered model.
+func (reg *Registry) Model(model interface{}) (Model, error) {
+	t, err := reg.table(model)
+	if err != nil {
+		return Model{}, err
+	}
+	return Model{t}, nil
+}
+
+// TableOf returns the database table a registered model maps to.
+func (reg *Registry) TableOf(model interface{}) (Table, error) {
+	m, err := reg.Model(model)
+	if err != nil {
+		return Table{}, err
+	}
+	return m.Table(), nil
+}
+
+// Columns returns the columns of a registered model in struct field order.
+func (reg *Registry) Columns(model interface{}) ([]Column, error) {
+	m, err := reg.Model(model)
+	if err != nil {
+		return nil, err
+	}
+	return m.Columns(), nil
+}
+
+// PrimaryKey returns the primary key columns of a registered model.
+func (reg *Registry) PrimaryKey(model interface{}) ([]Column, error) {
+	m, err := reg.Model(model)
+	if err != nil {
+		return nil, err
+	}
+	return m.PrimaryKey(), nil
+}
+
+// Models returns every model in the Registry ordered by model name.
+func (reg *Registry) Models() []Model {
+	reg.mu.RLock()
+	models := make([]Model, 0, len(reg.modMap))
+	for _, t := range reg.modMap {
+		models = append(models, Model{t})
+	}
+	reg.mu.RUnlock()
+
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Name() < models[j].Name()
+	})
+	return models
+}
+
+// TableOf returns the database table a model registered with the default
+// Registry maps to.
+func TableOf(model interface{}) (Table, error) {
+	return defaultRegistry.TableOf(model)
+}
+
+// Columns returns the columns of a model registered with the default Registry.
+func Columns(model interface{}) ([]Column, error) {
+	return defaultRegistry.Columns(model)
+}
+
+// PrimaryKey returns the primary key columns of a model registered with the
+// default Registry.
+func PrimaryKey(model interface{}) ([]Column, error) {
+	return defaultRegistry.PrimaryKey(model)
+}
+
+// Models returns every model registered with the default Registry.
+func Models() []Model {
+	return defaultRegistry.Models()
+}
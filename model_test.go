@@ -0,0 +1,125 @@
+package rdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type describedUser struct {
+	ID    int    `db:"database=app,table=users,col=id,pk,ai"`
+	Email string `db:"col=email"`
+	Name  string `db:"col=name,null"`
+}
+
+type describedPost struct {
+	ID       int    `db:"database=app,table=posts,col=id,pk"`
+	AuthorID int    `db:"col=author_id"`
+	Title    string `db:"col=title,fkmap=author_id.describedUser.ID"`
+}
+
+func TestTableOf(t *testing.T) {
+	defer reset()
+	if e := Register(describedUser{}); e != nil {
+		t.Fatalf("Not expecting error on TestTableOf but got: %s", e.Error())
+	}
+
+	tbl, err := TableOf(&describedUser{})
+	if err != nil {
+		t.Fatalf("Not expecting error on TableOf but got: %s", err.Error())
+	}
+
+	if tbl.Database() != "app" || tbl.Name() != "users" {
+		t.Errorf("Expected table app.users, got %s.%s", tbl.Database(), tbl.Name())
+	}
+
+	if tbl.String() != "app.users" {
+		t.Errorf("Expected table string app.users, got %s", tbl.String())
+	}
+}
+
+func TestNotRegisteredError(t *testing.T) {
+	defer reset()
+	if _, err := TableOf(describedUser{}); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Expected ErrNotRegistered, got %v", err)
+	}
+
+	if _, err := Columns(3); err == nil || errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Expected non-struct error, got %v", err)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	defer reset()
+	if e := Register(describedPost{}); e != nil {
+		t.Fatalf("Not expecting error on TestColumns but got: %s", e.Error())
+	}
+
+	cols, err := Columns(describedPost{})
+	if err != nil {
+		t.Fatalf("Not expecting error on Columns but got: %s", err.Error())
+	}
+
+	if len(cols) != 3 {
+		t.Fatalf("Expected 3 columns, got %d", len(cols))
+	}
+
+	title := cols[2]
+	if title.Field() != "Title" || title.Name() != "title" || title.Kind() != reflect.String {
+		t.Errorf("Expected Title/title/string column, got %s/%s/%s", title.Field(), title.Name(), title.Kind())
+	}
+
+	if !title.ForeignKey() || title.Relation() != "author_id.describedUser.ID" {
+		t.Errorf("Expected title to map foreign key author_id.describedUser.ID, got %q", title.Relation())
+	}
+
+	if title.PrimaryKey() || title.AutoIncrement() || title.Nullable() {
+		t.Errorf("Expected title to not be pk, ai or null")
+	}
+}
+
+func TestPrimaryKey(t *testing.T) {
+	defer reset()
+	if e := Register(describedUser{}); e != nil {
+		t.Fatalf("Not expecting error on TestPrimaryKey but got: %s", e.Error())
+	}
+
+	pk, err := PrimaryKey(describedUser{})
+	if err != nil {
+		t.Fatalf("Not expecting error on PrimaryKey but got: %s", err.Error())
+	}
+
+	if len(pk) != 1 {
+		t.Fatalf("Expected exactly one primary key column, got %d", len(pk))
+	}
+
+	if pk[0].Name() != "id" || !pk[0].PrimaryKey() || !pk[0].AutoIncrement() {
+		t.Errorf("Expected id to be the auto-increment primary key, got %s", pk[0].Name())
+	}
+}
+
+func TestModels(t *testing.T) {
+	defer reset()
+	if e := Register(describedUser{}); e != nil {
+		t.Fatalf("Not expecting error on TestModels but got: %s", e.Error())
+	}
+	if e := Register(describedPost{}); e != nil {
+		t.Fatalf("Not expecting error on TestModels but got: %s", e.Error())
+	}
+
+	models := Models()
+	if len(models) != 2 {
+		t.Fatalf("Expected 2 models, got %d", len(models))
+	}
+
+	if models[0].Type() != reflect.TypeOf(describedPost{}) {
+		t.Errorf("Expected models ordered by name, got %s first", models[0].Name())
+	}
+
+	// Descriptors are snapshots and may not be used to alter the registry
+	cols := models[1].Columns()
+	cols[0] = Column{}
+	if models[1].Columns()[0].Name() != "id" {
+		t.Errorf("Expected registry columns to be unaffected by descriptor changes")
+	}
+}
@@ -0,0 +1,1495 @@
+package rdb
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrSyntax is the kind of the TokenError returned when a statement can not
+// be parsed.
+var ErrSyntax = errors.New("Syntax error")
+
+// parser is a recursive-descent parser of the tokens of a statement.
+type parser struct {
+	query string
+	items []item // Tokens of the statement without whitespace and comments
+	i     int    // Index of the current token
+}
+
+// Parse parses a single SQL statement, optionally terminated by a semicolon,
+// into its syntax tree. Illegal tokens are reported in a *LexError, and the
+// first token which can not be parsed in a *TokenError of kind ErrSyntax.
+func Parse(query string) (Statement, error) {
+	p, err := newParser(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var stmt Statement
+	switch p.peek().Token {
+	case selectToken:
+		stmt, err = p.parseSelect()
+	case insertToken, replaceToken:
+		stmt, err = p.parseInsert()
+	case updateToken:
+		stmt, err = p.parseUpdate()
+	case deleteToken:
+		stmt, err = p.parseDelete()
+	default:
+		return nil, p.fail("expected statement")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.accept(semicolon)
+	if p.peek().Token != EOF {
+		return nil, p.fail("expected end of statement")
+	}
+	return stmt, nil
+}
+
+// newParser lexes query and returns a parser of its tokens. Optimizer hints
+// following the keyword of a statement are kept to be parsed as modifiers,
+// elsewhere MySQL ignores them and so are they. Versioned comments are not
+// supported as the statement depends on the version of the server.
+func newParser(query string) (*parser, error) {
+	l := lex(query)
+	var items []item
+	var versioned item
+	for _, it := range l.all() {
+		switch {
+		case it.Token == versionedComment && versioned.Token != versionedComment:
+			versioned = it
+		case it.Token == optimizerHint && len(items) > 0 && takesHints(items[len(items)-1].Token):
+			items = append(items, it)
+		case !isBlank(it.Token):
+			items = append(items, it)
+		}
+	}
+	if err := l.Err(); err != nil {
+		return nil, err
+	}
+	if versioned.Token == versionedComment {
+		return nil, syntaxError(query, versioned, "versioned comments are not supported")
+	}
+
+	// Keep the position of the end of the query for errors at EOF
+	eof := l.Next()
+	return &parser{query: query, items: append(items, eof)}, nil
+}
+
+// takesHints reports whether optimizer hints may follow token t.
+func takesHints(t token) bool {
+	switch t {
+	case selectToken, insertToken, replaceToken, updateToken, deleteToken, optimizerHint:
+		return true
+	}
+	return false
+}
+
+// peek returns the current token.
+func (p *parser) peek() item {
+	return p.items[p.i]
+}
+
+// peekAt returns the token n tokens after the current token.
+func (p *parser) peekAt(n int) item {
+	if p.i+n >= len(p.items) {
+		return p.items[len(p.items)-1]
+	}
+	return p.items[p.i+n]
+}
+
+// next returns the current token and moves to the next one.
+func (p *parser) next() item {
+	it := p.items[p.i]
+	if it.Token != EOF {
+		p.i++
+	}
+	return it
+}
+
+// accept moves past the current token if it is of kind t.
+func (p *parser) accept(t token) bool {
+	if p.peek().Token == t {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// expect moves past the current token which must be of kind t.
+func (p *parser) expect(t token, what string) (item, error) {
+	if p.peek().Token != t {
+		return item{}, p.fail("expected " + what)
+	}
+	return p.next(), nil
+}
+
+// fail returns a syntax error at the current token.
+func (p *parser) fail(detail string) error {
+	return syntaxError(p.query, p.peek(), detail)
+}
+
+// syntaxError returns a syntax error at token it of query.
+func syntaxError(query string, it item, detail string) error {
+	return &TokenError{
+		Value:  it.Value,
+		Pos:    it.Pos,
+		Line:   it.Line,
+		Col:    it.Col,
+		Kind:   ErrSyntax,
+		query:  query,
+		detail: detail,
+	}
+}
+
+// isIdent reports whether the current token can be used as an identifier.
+// Keywords MySQL does not reserve are identifiers where one is expected.
+func (p *parser) isIdent() bool {
+	switch p.peek().Token {
+	case identifier, offsetToken, escapeToken, endToken, ojToken, maxStatementTimeToken,
+		quickToken, returningToken:
+		return true
+	}
+	return false
+}
+
+// isAlias reports whether the current token is an alias given without AS,
+// rather than a reserved word the parser does not support.
+func (p *parser) isAlias() bool {
+	return p.isIdent() && p.peek().Token != returningToken &&
+		!isClauseWord(p.peek().Value) && !strings.EqualFold(p.peek().Value, "WITH")
+}
+
+// isWord reports whether the token n tokens after the current token is the
+// unquoted identifier word, a keyword the lexer does not tokenize.
+func (p *parser) isWord(n int, word string) bool {
+	it := p.peekAt(n)
+	return it.Token == identifier && strings.EqualFold(it.Value, word)
+}
+
+// acceptWord moves past the current token if it is the identifier word.
+func (p *parser) acceptWord(word string) bool {
+	if p.isWord(0, word) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// ident parses an identifier and returns its unquoted name.
+func (p *parser) ident(what string) (string, error) {
+	if !p.isIdent() {
+		return "", p.fail("expected " + what)
+	}
+	return unquoteIdent(p.next().Value), nil
+}
+
+// identList parses a parenthesized, comma separated list of identifiers,
+// which may be empty when allowEmpty.
+func (p *parser) identList(what string, allowEmpty bool) ([]string, error) {
+	if _, err := p.expect(lParen, "("); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	if allowEmpty && p.accept(rParen) {
+		return names, nil
+	}
+	for {
+		name, err := p.ident(what)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.accept(comma) {
+			break
+		}
+	}
+	if _, err := p.expect(rParen, ")"); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// parseSelectList parses the modifiers, select list and FROM clause of a
+// SELECT statement and ignores the rest of the statement, so the result
+// columns of a statement using syntax the parser does not support after its
+// FROM clause can still be analyzed.
+func parseSelectList(query string) (*SelectStmt, error) {
+	p, err := newParser(query)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseSelectHead()
+}
+
+// parseSelect parses a SELECT statement.
+func (p *parser) parseSelect() (*SelectStmt, error) {
+	s, err := p.parseSelectHead()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.accept(whereToken) {
+		if s.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept(groupByToken) {
+		if s.GroupBy, err = p.parseExprList(); err != nil {
+			return nil, err
+		}
+		if p.isWord(0, "WITH") && p.isWord(1, "ROLLUP") {
+			p.i += 2
+			s.Rollup = true
+		}
+	}
+	if p.accept(havingToken) {
+		if s.Having, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept(orderByToken) {
+		if s.OrderBy, err = p.parseOrderBy(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept(limitToken) {
+		if s.Limit, err = p.parseLimit(); err != nil {
+			return nil, err
+		}
+	}
+	if s.Locks, err = p.parseLocks(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseSelectHead parses a SELECT statement up to the end of its FROM clause.
+func (p *parser) parseSelectHead() (*SelectStmt, error) {
+	if _, err := p.expect(selectToken, "SELECT"); err != nil {
+		return nil, err
+	}
+
+	s := &SelectStmt{Modifiers: p.parseHints()}
+	for isSelectModifier(p.peek().Token) || p.peek().Token == maxStatementTimeToken {
+		it := p.next()
+		mod := keywordText[it.Token]
+		if it.Token == maxStatementTimeToken {
+			if _, err := p.expect(equals, "="); err != nil {
+				return nil, err
+			}
+			n, err := p.expect(naturalNumber, "statement time")
+			if err != nil {
+				return nil, err
+			}
+			mod += " = " + n.Value
+		}
+		s.Modifiers = append(s.Modifiers, mod)
+	}
+
+	for {
+		col, err := p.parseSelectExpr()
+		if err != nil {
+			return nil, err
+		}
+		s.Columns = append(s.Columns, col)
+		if !p.accept(comma) {
+			break
+		}
+	}
+
+	var err error
+	if p.accept(fromToken) {
+		if s.From, err = p.parseTableRefs(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// parseLocks parses the locking clauses ending a SELECT statement, FOR
+// UPDATE and FOR SHARE with their OF, NOWAIT and SKIP LOCKED options, and
+// LOCK IN SHARE MODE.
+func (p *parser) parseLocks() ([]*Lock, error) {
+	var locks []*Lock
+	for {
+		switch {
+		case p.isWord(0, "FOR") && (p.peekAt(1).Token == updateToken || p.isWord(1, "SHARE")):
+			p.next()
+			l := &Lock{Mode: "FOR " + strings.ToUpper(p.next().Value)}
+			if p.acceptWord("OF") {
+				for {
+					name, err := p.ident("table name")
+					if err != nil {
+						return nil, err
+					}
+					l.Tables = append(l.Tables, name)
+					if !p.accept(comma) {
+						break
+					}
+				}
+			}
+			switch {
+			case p.acceptWord("NOWAIT"):
+				l.Wait = "NOWAIT"
+			case p.isWord(0, "SKIP") && p.isWord(1, "LOCKED"):
+				p.i += 2
+				l.Wait = "SKIP LOCKED"
+			}
+			locks = append(locks, l)
+
+		case p.acceptWord("LOCK"):
+			if p.peek().Token != inToken || !p.isWord(1, "SHARE") || !p.isWord(2, "MODE") {
+				return nil, p.fail("expected IN SHARE MODE")
+			}
+			p.i += 3
+			locks = append(locks, &Lock{Mode: "LOCK IN SHARE MODE"})
+
+		default:
+			return locks, nil
+		}
+	}
+}
+
+// parseInsert parses an INSERT or REPLACE statement.
+func (p *parser) parseInsert() (*InsertStmt, error) {
+	s := &InsertStmt{Replace: p.next().Token == replaceToken}
+	s.Modifiers = p.parseHints()
+	if s.Replace {
+		s.Modifiers = append(s.Modifiers, p.parseModifiers(lowPriorityToken, delayedToken)...)
+	} else {
+		s.Modifiers = append(s.Modifiers, p.parseModifiers(lowPriorityToken, delayedToken, highPriorityToken, ignoreToken)...)
+	}
+	p.accept(intoToken)
+
+	var err error
+	if s.Table, err = p.parseTableName(); err != nil {
+		return nil, err
+	}
+	if s.Table.Alias != "" || len(s.Table.Hints) > 0 {
+		return nil, p.fail("expected VALUES, SET or SELECT")
+	}
+	if p.peek().Token == lParen && p.peekAt(1).Token != selectToken {
+		if s.Columns, err = p.identList("column name", true); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case p.accept(valuesToken):
+		for {
+			if _, err := p.expect(lParen, "("); err != nil {
+				return nil, err
+			}
+			row := []Expr{}
+			if p.peek().Token != rParen {
+				if row, err = p.parseExprList(); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := p.expect(rParen, ")"); err != nil {
+				return nil, err
+			}
+			s.Rows = append(s.Rows, row)
+			if !p.accept(comma) {
+				break
+			}
+		}
+	case p.peek().Token == setToken:
+		if len(s.Columns) > 0 {
+			return nil, p.fail("expected VALUES or SELECT")
+		}
+		p.next()
+		if s.Set, err = p.parseAssignments(); err != nil {
+			return nil, err
+		}
+	case p.peek().Token == selectToken:
+		if s.Select, err = p.parseSelect(); err != nil {
+			return nil, err
+		}
+	case p.peek().Token == lParen && p.peekAt(1).Token == selectToken:
+		p.next()
+		if s.Select, err = p.parseSelect(); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(rParen, ")"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.fail("expected VALUES, SET or SELECT")
+	}
+
+	if !s.Replace && p.accept(onDuplicateKeyUpdateToken) {
+		if s.OnDuplicate, err = p.parseAssignments(); err != nil {
+			return nil, err
+		}
+	}
+	if s.Returning, err = p.parseReturning(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseUpdate parses an UPDATE statement.
+func (p *parser) parseUpdate() (*UpdateStmt, error) {
+	p.next()
+	s := &UpdateStmt{Modifiers: append(p.parseHints(), p.parseModifiers(lowPriorityToken, ignoreToken)...)}
+
+	var err error
+	if s.Tables, err = p.parseTableRefs(); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(setToken, "SET"); err != nil {
+		return nil, err
+	}
+	if s.Set, err = p.parseAssignments(); err != nil {
+		return nil, err
+	}
+	if p.accept(whereToken) {
+		if s.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	// ORDER BY and LIMIT are only allowed updating a single table
+	if len(s.Tables) > 1 {
+		return s, nil
+	}
+	if _, ok := s.Tables[0].(*TableName); !ok {
+		return s, nil
+	}
+	if p.accept(orderByToken) {
+		if s.OrderBy, err = p.parseOrderBy(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept(limitToken) {
+		if s.Limit, err = p.parseLimit(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// parseDelete parses a single-table or multiple-table DELETE statement.
+func (p *parser) parseDelete() (*DeleteStmt, error) {
+	p.next()
+	s := &DeleteStmt{Modifiers: append(p.parseHints(), p.parseModifiers(lowPriorityToken, quickToken, ignoreToken)...)}
+
+	var err error
+	if !p.accept(fromToken) {
+		// DELETE tbl[.*], ... FROM table_references
+		if s.Targets, err = p.parseDeleteTargets(); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(fromToken, "FROM"); err != nil {
+			return nil, err
+		}
+		if s.From, err = p.parseTableRefs(); err != nil {
+			return nil, err
+		}
+	} else if err := p.parseDeleteFrom(s); err != nil {
+		return nil, err
+	}
+
+	if p.accept(whereToken) {
+		if s.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.Targets) > 0 {
+		return s, nil
+	}
+	if p.accept(orderByToken) {
+		if s.OrderBy, err = p.parseOrderBy(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept(limitToken) {
+		if s.Limit, err = p.parseLimit(); err != nil {
+			return nil, err
+		}
+	}
+	if s.Returning, err = p.parseReturning(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseDeleteFrom parses the table of a single-table DELETE, or the tables
+// and table references of DELETE FROM ... USING.
+func (p *parser) parseDeleteFrom(s *DeleteStmt) error {
+	start := p.i
+	if targets, err := p.parseDeleteTargets(); err == nil && p.accept(usingToken) {
+		s.Targets = targets
+		s.Using, err = p.parseTableRefs()
+		return err
+	}
+
+	p.i = start
+	t, err := p.parseTableName()
+	if err != nil {
+		return err
+	}
+	if len(t.Hints) > 0 {
+		return p.fail("expected WHERE")
+	}
+	s.From = []TableExpr{t}
+	return nil
+}
+
+// parseDeleteTargets parses the comma separated tables a multiple-table
+// DELETE deletes from, each optionally followed by .*.
+func (p *parser) parseDeleteTargets() ([]*TableName, error) {
+	var targets []*TableName
+	for {
+		name, err := p.ident("table name")
+		if err != nil {
+			return nil, err
+		}
+		t := &TableName{Name: name}
+		if p.peek().Token == period && p.peekAt(1).Token != astrisk {
+			p.next()
+			t.DB = t.Name
+			if t.Name, err = p.ident("table name"); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek().Token == period {
+			p.i += 2
+		}
+		targets = append(targets, t)
+		if !p.accept(comma) {
+			return targets, nil
+		}
+	}
+}
+
+// parseHints parses the optimizer hints following the keyword of a statement.
+func (p *parser) parseHints() []string {
+	var hints []string
+	for p.peek().Token == optimizerHint {
+		hints = append(hints, p.next().Value)
+	}
+	return hints
+}
+
+// parseModifiers parses the modifiers of a statement which are among
+// allowed.
+func (p *parser) parseModifiers(allowed ...token) []string {
+	var mods []string
+	for hasToken(allowed, p.peek().Token) {
+		mods = append(mods, keywordText[p.next().Token])
+	}
+	return mods
+}
+
+// parseAssignments parses the comma separated assignments of a SET or ON
+// DUPLICATE KEY UPDATE clause.
+func (p *parser) parseAssignments() ([]*Assignment, error) {
+	var set []*Assignment
+	for {
+		e, err := p.parseColumnRef()
+		if err != nil {
+			return nil, err
+		}
+		col, ok := e.(*ColumnRef)
+		if !ok {
+			return nil, p.fail("expected column name")
+		}
+		if !p.accept(equals) && !p.accept(assign) {
+			return nil, p.fail("expected =")
+		}
+		a := &Assignment{Column: col}
+		if a.Value, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		set = append(set, a)
+		if !p.accept(comma) {
+			return set, nil
+		}
+	}
+}
+
+// parseReturning parses an optional RETURNING clause.
+func (p *parser) parseReturning() ([]*SelectExpr, error) {
+	if !p.accept(returningToken) {
+		return nil, nil
+	}
+	var cols []*SelectExpr
+	for {
+		col, err := p.parseSelectExpr()
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+		if !p.accept(comma) {
+			return cols, nil
+		}
+	}
+}
+
+// parseSelectExpr parses an expression of a select list and its alias.
+func (p *parser) parseSelectExpr() (*SelectExpr, error) {
+	var e Expr
+	var err error
+	if p.peek().Token == astrisk {
+		p.next()
+		e = &StarExpr{}
+	} else if e, err = p.parseExpr(); err != nil {
+		return nil, err
+	}
+
+	col := &SelectExpr{Expr: e}
+	if p.accept(asToken) {
+		if p.peek().Token == quotedString {
+			col.Alias = unquote(p.next().Value)
+		} else if col.Alias, err = p.ident("alias"); err != nil {
+			return nil, err
+		}
+	} else if p.isAlias() {
+		col.Alias = unquoteIdent(p.next().Value)
+	}
+	return col, nil
+}
+
+// parseOrderBy parses the expressions of an ORDER BY clause.
+func (p *parser) parseOrderBy() ([]*OrderExpr, error) {
+	var order []*OrderExpr
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		o := &OrderExpr{Expr: e}
+		if p.accept(descToken) {
+			o.Desc = true
+		} else {
+			p.accept(ascToken)
+		}
+		order = append(order, o)
+		if !p.accept(comma) {
+			return order, nil
+		}
+	}
+}
+
+// parseLimit parses LIMIT count, LIMIT offset, count and LIMIT count OFFSET
+// offset.
+func (p *parser) parseLimit() (*Limit, error) {
+	count, err := p.parseLimitValue()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Limit{Count: count}
+	if p.accept(comma) {
+		l.Offset = count
+		if l.Count, err = p.parseLimitValue(); err != nil {
+			return nil, err
+		}
+	} else if p.accept(offsetToken) {
+		if l.Offset, err = p.parseLimitValue(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// parseLimitValue parses a number or parameter of a LIMIT clause.
+func (p *parser) parseLimitValue() (Expr, error) {
+	switch it := p.peek(); it.Token {
+	case naturalNumber:
+		p.next()
+		return &Literal{Value: it.Value, kind: it.Token}, nil
+	case placeholder, namedParam:
+		return p.parsePrimary()
+	}
+	return nil, p.fail("expected row count")
+}
+
+// parseTableRefs parses the comma separated table references of a FROM
+// clause.
+func (p *parser) parseTableRefs() ([]TableExpr, error) {
+	var refs []TableExpr
+	for {
+		ref, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+		if !p.accept(comma) {
+			return refs, nil
+		}
+	}
+}
+
+// parseTableRef parses a table reference and the tables joined to it.
+func (p *parser) parseTableRef() (TableExpr, error) {
+	left, err := p.parseTableFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for isJoin(p.peek().Token) {
+		it := p.next()
+		j := &JoinExpr{Left: left, Join: keywordText[it.Token]}
+		if j.Right, err = p.parseTableFactor(); err != nil {
+			return nil, err
+		}
+
+		if p.accept(onToken) {
+			if j.On, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		} else if p.accept(usingToken) {
+			if j.Using, err = p.identList("column", false); err != nil {
+				return nil, err
+			}
+		}
+		left = j
+	}
+	return left, nil
+}
+
+// parseTableFactor parses a table name, a derived table, a parenthesized
+// list of table references or an ODBC outer join.
+func (p *parser) parseTableFactor() (TableExpr, error) {
+	switch p.peek().Token {
+	case lParen:
+		if p.peekAt(1).Token == selectToken {
+			p.next()
+			sel, err := p.parseSelect()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(rParen, ")"); err != nil {
+				return nil, err
+			}
+			p.accept(asToken)
+			alias, err := p.ident("derived table alias")
+			if err != nil {
+				return nil, err
+			}
+			return &DerivedTable{Select: sel, Alias: alias}, nil
+		}
+
+		p.next()
+		refs, err := p.parseTableRefs()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(rParen, ")"); err != nil {
+			return nil, err
+		}
+		return &ParenTableExpr{Tables: refs}, nil
+
+	case lBrace:
+		p.next()
+		if _, err := p.expect(ojToken, "OJ"); err != nil {
+			return nil, err
+		}
+		ref, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(rBrace, "}"); err != nil {
+			return nil, err
+		}
+		return &OJTableExpr{Table: ref}, nil
+	}
+
+	return p.parseTableName()
+}
+
+// parseTableName parses a table name with its partitions, alias and index
+// hints.
+func (p *parser) parseTableName() (*TableName, error) {
+	name, err := p.ident("table name")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &TableName{Name: name}
+	if p.accept(period) {
+		t.DB = t.Name
+		if t.Name, err = p.ident("table name"); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept(partitionToken) {
+		if t.Partitions, err = p.identList("partition name", false); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept(asToken) {
+		if t.Alias, err = p.ident("table alias"); err != nil {
+			return nil, err
+		}
+	} else if p.isAlias() {
+		t.Alias = unquoteIdent(p.next().Value)
+	}
+
+	for isIndexHint(p.peek().Token) {
+		it := p.next()
+		h := &IndexHint{Type: keywordText[it.Token]}
+		switch p.peek().Token {
+		case forJoinToken, forOrderByToken, forGroupByToken:
+			h.For = keywordText[p.next().Token]
+		}
+		// Only USE INDEX may be given an empty list of indexes
+		empty := it.Token == useIndexToken || it.Token == useKeyToken
+		if h.Indexes, err = p.identList("index name", empty); err != nil {
+			return nil, err
+		}
+		t.Hints = append(t.Hints, h)
+	}
+	return t, nil
+}
+
+// isIndexHint reports whether t starts an index hint.
+func isIndexHint(t token) bool {
+	switch t {
+	case useIndexToken, useKeyToken, ignoreIndexToken, ignoreKeyToken, forceIndexToken, forceKeyToken:
+		return true
+	}
+	return false
+}
+
+// parseExprList parses a comma separated list of expressions.
+func (p *parser) parseExprList() ([]Expr, error) {
+	var exprs []Expr
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		if !p.accept(comma) {
+			return exprs, nil
+		}
+	}
+}
+
+// binaryLevels holds the binary operators by increasing precedence, from OR
+// to ^. NOT and the comparison operators are parsed between AND and |.
+var binaryLevels = [][]token{
+	{orToken, logicalOr},
+	{xorToken},
+	{andToken, logicalAnd},
+	{pipe},
+	{ampersand},
+	{shiftLeft, shiftRight},
+	{plus, minus},
+	{astrisk, slash, divToken, percent, modToken},
+	{caret},
+}
+
+// predicateLevel is the level of binaryLevels parsed after NOT and the
+// comparison operators.
+const predicateLevel = 3
+
+// parseExpr parses an expression.
+func (p *parser) parseExpr() (Expr, error) {
+	return p.parseBinary(0)
+}
+
+// parseBinary parses the left associative binary operators of binaryLevels
+// from level on.
+func (p *parser) parseBinary(level int) (Expr, error) {
+	operand := func() (Expr, error) {
+		switch {
+		case level+1 == predicateLevel:
+			return p.parseNot()
+		case level+1 < len(binaryLevels):
+			return p.parseBinary(level + 1)
+		}
+		return p.parseUnary()
+	}
+
+	l, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for hasToken(binaryLevels[level], p.peek().Token) {
+		op := p.next()
+		r, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		l = &BinaryExpr{Op: operatorText(op), L: l, R: r}
+	}
+	return l, nil
+}
+
+// parseNot parses NOT and the comparison operators.
+func (p *parser) parseNot() (Expr, error) {
+	if p.accept(notToken) {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: notStmt, X: x}, nil
+	}
+	return p.parsePredicate()
+}
+
+// parsePredicate parses comparisons, IS, IN, BETWEEN, LIKE and REGEXP.
+func (p *parser) parsePredicate() (Expr, error) {
+	x, err := p.parseBinary(predicateLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		it := p.peek()
+		switch {
+		case isComparison(it.Token):
+			p.next()
+			r, err := p.parseBinary(predicateLevel)
+			if err != nil {
+				return nil, err
+			}
+			x = &BinaryExpr{Op: operatorText(it), L: x, R: r}
+
+		case it.Token == isToken:
+			p.next()
+			e := &IsExpr{X: x, Not: p.accept(notToken)}
+			switch v := p.peek(); {
+			case v.Token == nullToken || v.Token == trueToken || v.Token == falseToken:
+				e.Value = keywordText[v.Token]
+			case v.Token == identifier && strings.EqualFold(v.Value, "UNKNOWN"):
+				e.Value = strings.ToUpper(v.Value)
+			default:
+				return nil, p.fail("expected NULL, TRUE, FALSE or UNKNOWN")
+			}
+			p.next()
+			x = e
+
+		case it.Token == inToken || it.Token == betweenToken || it.Token == likeToken || it.Token == regexpToken ||
+			(it.Token == notToken && isNegatable(p.peekAt(1).Token)):
+			not := p.accept(notToken)
+			if x, err = p.parseNegatable(x, not); err != nil {
+				return nil, err
+			}
+
+		default:
+			return x, nil
+		}
+	}
+}
+
+// isComparison reports whether t is a comparison operator.
+func isComparison(t token) bool {
+	switch t {
+	case equals, nullSafeEqual, lessThan, greaterThan, lessOrEqual, greaterOrEqual, notEqual, assign:
+		return true
+	}
+	return false
+}
+
+// isNegatable reports whether t is an operator which may follow NOT.
+func isNegatable(t token) bool {
+	return t == inToken || t == betweenToken || t == likeToken || t == regexpToken
+}
+
+// parseNegatable parses the [NOT] IN, BETWEEN, LIKE or REGEXP predicate
+// applied to x.
+func (p *parser) parseNegatable(x Expr, not bool) (Expr, error) {
+	switch op := p.next(); op.Token {
+	case inToken:
+		e := &InExpr{X: x, Not: not}
+		if _, err := p.expect(lParen, "("); err != nil {
+			return nil, err
+		}
+		var err error
+		if p.peek().Token == selectToken {
+			e.Select, err = p.parseSelect()
+		} else {
+			e.List, err = p.parseExprList()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(rParen, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case betweenToken:
+		lo, err := p.parseBinary(predicateLevel)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(andToken, "AND"); err != nil {
+			return nil, err
+		}
+		hi, err := p.parseBinary(predicateLevel)
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{X: x, Not: not, Lo: lo, Hi: hi}, nil
+
+	case likeToken:
+		pattern, err := p.parseBinary(predicateLevel)
+		if err != nil {
+			return nil, err
+		}
+		e := &LikeExpr{X: x, Not: not, Pattern: pattern}
+		if p.accept(escapeToken) {
+			if e.Escape, err = p.parsePrimary(); err != nil {
+				return nil, err
+			}
+		}
+		return e, nil
+
+	default:
+		r, err := p.parseBinary(predicateLevel)
+		if err != nil {
+			return nil, err
+		}
+		opText := regexpStmt
+		if not {
+			opText = "NOT " + regexpStmt
+		}
+		return &BinaryExpr{Op: opText, L: x, R: r}, nil
+	}
+}
+
+// parseUnary parses the prefix operators -, +, ~, ! and BINARY.
+func (p *parser) parseUnary() (Expr, error) {
+	switch it := p.peek(); it.Token {
+	case minus, plus, tilde, bang:
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: it.Value, X: x}, nil
+	}
+	if p.acceptWord("BINARY") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "BINARY", X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses literals, parameters, variables, column references,
+// function calls, CASE, EXISTS and INTERVAL expressions and parenthesized
+// expressions, row constructors and subqueries.
+func (p *parser) parsePrimary() (Expr, error) {
+	it := p.peek()
+	switch it.Token {
+	case naturalNumber, integer, fixedNumber, floatingPointNumber, quotedString, charsetString,
+		hexNumber, bitNumber, trueToken, falseToken, nullToken, defaultToken:
+		p.next()
+		return &Literal{Value: it.Value, kind: it.Token}, nil
+
+	case placeholder:
+		p.next()
+		return &Param{}, nil
+
+	case namedParam:
+		p.next()
+		return &Param{Name: it.Value[1:]}, nil
+
+	case userVariable, systemVariable:
+		p.next()
+		return &Variable{Name: it.Value}, nil
+
+	case lParen:
+		p.next()
+		var e Expr
+		if p.peek().Token == selectToken {
+			sel, err := p.parseSelect()
+			if err != nil {
+				return nil, err
+			}
+			e = &Subquery{Select: sel}
+		} else {
+			exprs, err := p.parseExprList()
+			if err != nil {
+				return nil, err
+			}
+			e = &ParenExpr{X: exprs[0]}
+			if len(exprs) > 1 {
+				e = &TupleExpr{Exprs: exprs}
+			}
+		}
+		if _, err := p.expect(rParen, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case existsToken:
+		p.next()
+		if _, err := p.expect(lParen, "("); err != nil {
+			return nil, err
+		}
+		sel, err := p.parseSelect()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(rParen, ")"); err != nil {
+			return nil, err
+		}
+		return &ExistsExpr{Select: sel}, nil
+
+	case caseToken:
+		return p.parseCase()
+	}
+
+	// Functions named like keywords
+	if hasToken(keywordFuncs, it.Token) && p.peekAt(1).Token == lParen {
+		return p.parseFuncCall()
+	}
+
+	if !p.isIdent() {
+		return nil, p.fail("expected expression")
+	}
+	if p.peekAt(1).Token == lParen {
+		switch strings.ToUpper(it.Value) {
+		case "CAST":
+			return p.parseCast()
+		case "CONVERT":
+			return p.parseConvert()
+		}
+		return p.parseFuncCall()
+	}
+	if p.isWord(0, "INTERVAL") {
+		return p.parseInterval()
+	}
+	if temporalTypes[strings.ToUpper(it.Value)] && p.peekAt(1).Token == quotedString {
+		p.next()
+		v := p.next()
+		return &TemporalLiteral{Type: strings.ToUpper(it.Value), Value: &Literal{Value: v.Value, kind: v.Token}}, nil
+	}
+
+	col, err := p.parseColumnRef()
+	if err != nil {
+		return nil, err
+	}
+	if op := p.peek(); op.Token == jsonExtract || op.Token == jsonUnquote {
+		p.next()
+		path, err := p.expect(quotedString, "JSON path")
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryExpr{Op: op.Value, L: col, R: &Literal{Value: path.Value, kind: path.Token}}, nil
+	}
+	return col, nil
+}
+
+// keywordFuncs holds the keywords which are also names of functions, such as
+// VALUES(col) of an ON DUPLICATE KEY UPDATE clause.
+var keywordFuncs = []token{modToken, insertToken, replaceToken, valuesToken}
+
+// parseCast parses CAST(expr AS type). The type is kept as written.
+func (p *parser) parseCast() (Expr, error) {
+	p.next()
+	p.next()
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(asToken, "AS"); err != nil {
+		return nil, err
+	}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	return &CastExpr{X: x, Type: t}, nil
+}
+
+// parseConvert parses CONVERT(expr, type) and CONVERT(expr USING charset).
+// The type is kept as written.
+func (p *parser) parseConvert() (Expr, error) {
+	p.next()
+	p.next()
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &ConvertExpr{X: x}
+	if p.accept(usingToken) {
+		if e.Charset, err = p.ident("character set"); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(rParen, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	if _, err := p.expect(comma, ", or USING"); err != nil {
+		return nil, err
+	}
+	if e.Type, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// parseType returns the text of the type of a cast up to and including the
+// closing parenthesis of the cast.
+func (p *parser) parseType() (string, error) {
+	start := p.peek().Pos
+	for depth := 0; depth > 0 || p.peek().Token != rParen; p.next() {
+		switch p.peek().Token {
+		case EOF:
+			return "", p.fail("expected )")
+		case lParen:
+			depth++
+		case rParen:
+			depth--
+		}
+	}
+	if p.peek().Pos == start {
+		return "", p.fail("expected type")
+	}
+	t := strings.TrimSpace(p.query[start:p.peek().Pos])
+	p.next()
+	return t, nil
+}
+
+// intervalUnits holds the units of INTERVAL expressions.
+var intervalUnits = map[string]bool{
+	"MICROSECOND": true, "SECOND": true, "MINUTE": true, "HOUR": true, "DAY": true,
+	"WEEK": true, "MONTH": true, "QUARTER": true, "YEAR": true,
+	"SECOND_MICROSECOND": true, "MINUTE_MICROSECOND": true, "MINUTE_SECOND": true,
+	"HOUR_MICROSECOND": true, "HOUR_SECOND": true, "HOUR_MINUTE": true,
+	"DAY_MICROSECOND": true, "DAY_SECOND": true, "DAY_MINUTE": true, "DAY_HOUR": true,
+	"YEAR_MONTH": true,
+}
+
+// temporalTypes holds the types of temporal literals, DATE '2020-01-01'.
+var temporalTypes = map[string]bool{"DATE": true, "TIME": true, "TIMESTAMP": true}
+
+// parseInterval parses INTERVAL expr unit.
+func (p *parser) parseInterval() (Expr, error) {
+	p.next()
+	x, err := p.parseBinary(predicateLevel)
+	if err != nil {
+		return nil, err
+	}
+	if it := p.peek(); it.Token != identifier || !intervalUnits[strings.ToUpper(it.Value)] {
+		return nil, p.fail("expected interval unit")
+	}
+	return &IntervalExpr{X: x, Unit: strings.ToUpper(p.next().Value)}, nil
+}
+
+// parseColumnRef parses [db.][tbl.]col and [db.]tbl.*.
+func (p *parser) parseColumnRef() (Expr, error) {
+	var parts []string
+	for {
+		name, err := p.ident("column name")
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, name)
+		if len(parts) == 3 || p.peek().Token != period {
+			break
+		}
+		p.next()
+		if p.peek().Token == astrisk {
+			p.next()
+			return &StarExpr{Qualifier: parts}, nil
+		}
+	}
+	col := &ColumnRef{Name: parts[len(parts)-1]}
+	if len(parts) > 1 {
+		col.Qualifier = parts[:len(parts)-1]
+	}
+	return col, nil
+}
+
+// parseFuncCall parses a function call, including COUNT(*), aggregates of
+// DISTINCT values and the ORDER BY and SEPARATOR of GROUP_CONCAT.
+func (p *parser) parseFuncCall() (Expr, error) {
+	f := &FuncCall{Name: p.next().Value}
+	p.next()
+	if p.accept(rParen) {
+		return f, nil
+	}
+
+	if p.peek().Token == astrisk && p.peekAt(1).Token == rParen {
+		p.next()
+		f.Args = []Expr{&StarExpr{}}
+	} else {
+		f.Distinct = p.accept(distinctToken)
+		args, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		f.Args = args
+	}
+
+	if strings.EqualFold(f.Name, "GROUP_CONCAT") {
+		var err error
+		if p.accept(orderByToken) {
+			if f.OrderBy, err = p.parseOrderBy(); err != nil {
+				return nil, err
+			}
+		}
+		if p.acceptWord("SEPARATOR") {
+			sep, err := p.expect(quotedString, "separator string")
+			if err != nil {
+				return nil, err
+			}
+			f.Separator = &Literal{Value: sep.Value, kind: sep.Token}
+		}
+	}
+	if _, err := p.expect(rParen, ")"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseCase parses a CASE expression.
+func (p *parser) parseCase() (Expr, error) {
+	p.next()
+	e := &CaseExpr{}
+	var err error
+	if p.peek().Token != whenToken {
+		if e.Operand, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	for p.accept(whenToken) {
+		w := &When{}
+		if w.Cond, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(thenToken, "THEN"); err != nil {
+			return nil, err
+		}
+		if w.Result, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		e.Whens = append(e.Whens, w)
+	}
+	if len(e.Whens) == 0 {
+		return nil, p.fail("expected WHEN")
+	}
+
+	if p.accept(elseToken) {
+		if e.Else, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.expect(endToken, "END"); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// operatorText returns the canonical text of an operator token.
+func operatorText(it item) string {
+	if kw, ok := keywordText[it.Token]; ok {
+		return kw
+	}
+	return it.Value
+}
+
+// hasToken reports whether tokens holds t.
+func hasToken(tokens []token, t token) bool {
+	for _, tt := range tokens {
+		if tt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// isSelectModifier reports whether t may follow SELECT before the select list.
+func isSelectModifier(t token) bool {
+	switch t {
+	case allToken, distinctToken, highPriorityToken, straightJoinToken,
+		sqlSmallResultToken, sqlBigResultToken, sqlBufferResultToken,
+		sqlCacheToken, sqlNoCacheToken, sqlCalcFoundRowsToken:
+		return true
+	}
+	return false
+}
+
+// isJoin reports whether t joins table references.
+func isJoin(t token) bool {
+	switch t {
+	case joinToken, straightJoinToken, crossJoinToken, innerJoinToken, naturalJoinToken,
+		naturalLeftJoinToken, naturalLeftOuterJoinToken, naturalRightJoinToken,
+		naturalRightOuterJoinToken, leftJoinToken, leftOuterJoinToken,
+		rightJoinToken, rightOuterJoinToken:
+		return true
+	}
+	return false
+}
+
+// isClauseWord reports whether an identifier is a reserved word starting a
+// clause which the lexer does not tokenize, and so can not be an alias.
+func isClauseWord(s string) bool {
+	switch strings.ToUpper(s) {
+	case "UNION", "WINDOW", "FOR", "LOCK", "PROCEDURE":
+		return true
+	}
+	return false
+}
+
+// unquoteIdent returns an identifier without its enclosing backticks.
+func unquoteIdent(s string) string {
+	if len(s) >= 2 && s[0] == '`' && s[len(s)-1] == '`' {
+		return strings.Replace(s[1:len(s)-1], "``", "`", -1)
+	}
+	return s
+}
+
+// unquote returns a quoted string without its enclosing quotes, decoding
+// doubled quotes and backslash escape sequences. Like MySQL, \% and \_ keep
+// their backslash so they match literally in LIKE patterns.
+func unquote(s string) string {
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return s
+	}
+	q, body := s[0], s[1:len(s)-1]
+	if strings.IndexByte(body, '\\') < 0 && strings.IndexByte(body, q) < 0 {
+		return body
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\\' && i+1 < len(body):
+			i++
+			switch c = body[i]; c {
+			case '0':
+				c = 0
+			case 'b':
+				c = '\b'
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'Z':
+				c = 0x1a
+			case '%', '_':
+				b.WriteByte('\\')
+			}
+		case c == q:
+			// A doubled quote
+			i++
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package rdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSelect(t *testing.T) {
+	stmt, err := Parse("SELECT DISTINCT u.id, COUNT(*) n FROM app.users AS u " +
+		"LEFT JOIN orders o USE INDEX (user_idx) ON o.user_id = u.id AND o.total >= ? " +
+		"WHERE u.email LIKE '%@example.com' OR u.id IN (1, 2) " +
+		"GROUP BY u.id HAVING n > 1 ORDER BY n DESC, u.id LIMIT 10, 20;")
+	if err != nil {
+		t.Fatalf("Not expecting error on Parse but got: %s", err.Error())
+	}
+
+	expected := &SelectStmt{
+		Modifiers: []string{"DISTINCT"},
+		Columns: []*SelectExpr{
+			{Expr: &ColumnRef{Qualifier: []string{"u"}, Name: "id"}},
+			{Expr: &FuncCall{Name: "COUNT", Args: []Expr{&StarExpr{}}}, Alias: "n"},
+		},
+		From: []TableExpr{&JoinExpr{
+			Left: &TableName{DB: "app", Name: "users", Alias: "u"},
+			Join: "LEFT JOIN",
+			Right: &TableName{Name: "orders", Alias: "o", Hints: []*IndexHint{
+				{Type: "USE INDEX", Indexes: []string{"user_idx"}},
+			}},
+			On: &BinaryExpr{
+				Op: "AND",
+				L: &BinaryExpr{Op: "=",
+					L: &ColumnRef{Qualifier: []string{"o"}, Name: "user_id"},
+					R: &ColumnRef{Qualifier: []string{"u"}, Name: "id"}},
+				R: &BinaryExpr{Op: ">=",
+					L: &ColumnRef{Qualifier: []string{"o"}, Name: "total"},
+					R: &Param{}},
+			},
+		}},
+		Where: &BinaryExpr{
+			Op: "OR",
+			L: &LikeExpr{
+				X:       &ColumnRef{Qualifier: []string{"u"}, Name: "email"},
+				Pattern: &Literal{Value: "'%@example.com'", kind: quotedString},
+			},
+			R: &InExpr{
+				X:    &ColumnRef{Qualifier: []string{"u"}, Name: "id"},
+				List: []Expr{&Literal{Value: "1", kind: naturalNumber}, &Literal{Value: "2", kind: naturalNumber}},
+			},
+		},
+		GroupBy: []Expr{&ColumnRef{Qualifier: []string{"u"}, Name: "id"}},
+		Having:  &BinaryExpr{Op: ">", L: &ColumnRef{Name: "n"}, R: &Literal{Value: "1", kind: naturalNumber}},
+		OrderBy: []*OrderExpr{
+			{Expr: &ColumnRef{Name: "n"}, Desc: true},
+			{Expr: &ColumnRef{Qualifier: []string{"u"}, Name: "id"}},
+		},
+		Limit: &Limit{Count: &Literal{Value: "20", kind: naturalNumber}, Offset: &Literal{Value: "10", kind: naturalNumber}},
+	}
+	if !reflect.DeepEqual(stmt, expected) {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, stmt)
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"select * from t", "SELECT * FROM `t`"},
+		{"SELECT SQL_CALC_FOUND_ROWS t.*, `a b`.c AS 'x' FROM db.t PARTITION (p0, p1) t2, `a b`",
+			"SELECT SQL_CALC_FOUND_ROWS `t`.*, `a b`.`c` AS `x` FROM `db`.`t` PARTITION (`p0`, `p1`) AS `t2`, `a b`"},
+		{"SELECT a FROM t1 NATURAL LEFT OUTER JOIN t2 JOIN t3 USING (id) STRAIGHT_JOIN t4 ON t4.x = t3.x",
+			"SELECT `a` FROM `t1` NATURAL LEFT OUTER JOIN `t2` JOIN `t3` USING (`id`) STRAIGHT_JOIN `t4` ON `t4`.`x` = `t3`.`x`"},
+		{"SELECT a FROM (t1, t2) CROSS JOIN (SELECT b FROM t3) AS d, { OJ t4 LEFT OUTER JOIN t5 ON t4.id = t5.id }",
+			"SELECT `a` FROM (`t1`, `t2`) CROSS JOIN (SELECT `b` FROM `t3`) AS `d`, { OJ `t4` LEFT OUTER JOIN `t5` ON `t4`.`id` = `t5`.`id` }"},
+		{"SELECT a FROM t FORCE INDEX FOR ORDER BY (i1, i2) IGNORE KEY (i3) USE INDEX ()",
+			"SELECT `a` FROM `t` FORCE INDEX FOR ORDER BY (`i1`, `i2`) IGNORE KEY (`i3`) USE INDEX ()"},
+		{"SELECT NOT a AND b OR c XOR d, (a + b) * -c - - 1, !x, ~y ^ 2 | 3 & 4 << 1",
+			"SELECT NOT `a` AND `b` OR `c` XOR `d`, (`a` + `b`) * -`c` - - 1, !`x`, ~`y` ^ 2 | 3 & 4 << 1"},
+		{"SELECT a FROM t WHERE b NOT BETWEEN 1 AND 2 AND c IS NOT NULL AND d NOT LIKE 'x!%' ESCAPE '!' AND e NOT IN (SELECT f FROM g)",
+			"SELECT `a` FROM `t` WHERE `b` NOT BETWEEN 1 AND 2 AND `c` IS NOT NULL AND `d` NOT LIKE 'x!%' ESCAPE '!' AND `e` NOT IN (SELECT `f` FROM `g`)"},
+		{"SELECT CASE WHEN a > 1 THEN 'x' ELSE 'y' END, CASE b WHEN 1 THEN 2 END, CAST(c AS DECIMAL(10, 2)), COUNT(DISTINCT d, e)",
+			"SELECT CASE WHEN `a` > 1 THEN 'x' ELSE 'y' END, CASE `b` WHEN 1 THEN 2 END, CAST(`c` AS DECIMAL(10, 2)), COUNT(DISTINCT `d`, `e`)"},
+		{"SELECT @v := 1, @@session.sql_mode, :name, doc->>'$.a', (a, b) = (1, 2) FROM t WHERE EXISTS (SELECT 1) AND x REGEXP '^a'",
+			"SELECT @v := 1, @@session.sql_mode, :name, `doc` ->> '$.a', (`a`, `b`) = (1, 2) FROM `t` WHERE EXISTS (SELECT 1) AND `x` REGEXP '^a'"},
+		{"SELECT a, SUM(b) FROM t GROUP BY a WITH ROLLUP HAVING SUM(b) <> 0 ORDER BY a ASC LIMIT ? OFFSET ?",
+			"SELECT `a`, SUM(`b`) FROM `t` GROUP BY `a` WITH ROLLUP HAVING SUM(`b`) <> 0 ORDER BY `a` LIMIT ? OFFSET ?"},
+		{"SELECT 0x1F, X'0f', b'1', _utf8mb4 'a\\'b', N'c', TRUE - 1, a IS NOT FALSE FROM t WHERE b = -0b1",
+			"SELECT 0x1F, X'0f', b'1', _utf8mb4 'a\\'b', N'c', TRUE - 1, `a` IS NOT FALSE FROM `t` WHERE `b` = - 0b1"},
+		{"SELECT a FROM t WHERE id = ? FOR UPDATE", "SELECT `a` FROM `t` WHERE `id` = ? FOR UPDATE"},
+		{"SELECT a FROM t, u LIMIT 1 for share of t, `u` skip locked for update of u nowait",
+			"SELECT `a` FROM `t`, `u` LIMIT 1 FOR SHARE OF `t`, `u` SKIP LOCKED FOR UPDATE OF `u` NOWAIT"},
+		{"SELECT a FROM t WHERE b IN (SELECT b FROM u LOCK IN SHARE MODE) lock in share mode",
+			"SELECT `a` FROM `t` WHERE `b` IN (SELECT `b` FROM `u` LOCK IN SHARE MODE) LOCK IN SHARE MODE"},
+		{"SELECT NOW() - interval 1 day, DATE_ADD(a, INTERVAL b + 1 hour_minute), date '2020-01-01', TIME '10:00', TIMESTAMP '2020-01-01 10:00:00'",
+			"SELECT NOW() - INTERVAL 1 DAY, DATE_ADD(`a`, INTERVAL `b` + 1 HOUR_MINUTE), DATE '2020-01-01', TIME '10:00', TIMESTAMP '2020-01-01 10:00:00'"},
+		{"SELECT BINARY 'x', binary a = b, CONVERT(a USING utf8mb4), CONVERT(b, CHAR(10)), INTERVAL(a, 1, 2)",
+			"SELECT BINARY 'x', BINARY `a` = `b`, CONVERT(`a` USING utf8mb4), CONVERT(`b`, CHAR(10)), INTERVAL(`a`, 1, 2)"},
+		{"SELECT GROUP_CONCAT(DISTINCT a, b ORDER BY b DESC, a SEPARATOR ', '), group_concat(c separator '') FROM t GROUP BY d",
+			"SELECT GROUP_CONCAT(DISTINCT `a`, `b` ORDER BY `b` DESC, `a` SEPARATOR ', '), group_concat(`c` SEPARATOR '') FROM `t` GROUP BY `d`"},
+		{"insert low_priority ignore t (a, b) values (1, DEFAULT), (?, ?) on duplicate key update b = VALUES(b) + 1",
+			"INSERT LOW_PRIORITY IGNORE INTO `t` (`a`, `b`) VALUES (1, DEFAULT), (?, ?) ON DUPLICATE KEY UPDATE `b` = VALUES(`b`) + 1"},
+		{"INSERT INTO db.t PARTITION (p0) VALUES ()", "INSERT INTO `db`.`t` PARTITION (`p0`) VALUES ()"},
+		{"INSERT INTO t SET a = 1, t.b = REPLACE(c, 'x', 'y') RETURNING id, a AS x",
+			"INSERT INTO `t` SET `a` = 1, `t`.`b` = REPLACE(`c`, 'x', 'y') RETURNING `id`, `a` AS `x`"},
+		{"INSERT INTO t (a) SELECT b FROM u WHERE c > 1", "INSERT INTO `t` (`a`) SELECT `b` FROM `u` WHERE `c` > 1"},
+		{"SELECT .5, -.5e3, t.a-.25 FROM t", "SELECT .5, -.5e3, `t`.`a` - .25 FROM `t`"},
+		{"SELECT /*+ BKA(t) */ /*+ NO_ICP(t) */ DISTINCT a FROM t /*+ ignored */ WHERE a IN (SELECT /*+ NO_MERGE() */ b FROM u)",
+			"SELECT /*+ BKA(t) */ /*+ NO_ICP(t) */ DISTINCT `a` FROM `t` WHERE `a` IN (SELECT /*+ NO_MERGE() */ `b` FROM `u`)"},
+		{"INSERT /*+ SET_VAR(foreign_key_checks=OFF) */ IGNORE INTO t VALUES ()", "INSERT /*+ SET_VAR(foreign_key_checks=OFF) */ IGNORE INTO `t` VALUES ()"},
+		{"UPDATE /*+ NO_MERGE(t) */ t SET a = 1", "UPDATE /*+ NO_MERGE(t) */ `t` SET `a` = 1"},
+		{"DELETE /*+ MAX_EXECUTION_TIME(10) */ QUICK FROM t", "DELETE /*+ MAX_EXECUTION_TIME(10) */ QUICK FROM `t`"},
+		{"REPLACE DELAYED t (a) VALUES (1)", "REPLACE DELAYED INTO `t` (`a`) VALUES (1)"},
+		{"UPDATE LOW_PRIORITY t SET a = a + 1, b = DEFAULT WHERE id = :id ORDER BY a DESC LIMIT 10",
+			"UPDATE LOW_PRIORITY `t` SET `a` = `a` + 1, `b` = DEFAULT WHERE `id` = :id ORDER BY `a` DESC LIMIT 10"},
+		{"UPDATE t JOIN u ON u.id = t.u_id SET t.a = u.a WHERE u.b IS NULL",
+			"UPDATE `t` JOIN `u` ON `u`.`id` = `t`.`u_id` SET `t`.`a` = `u`.`a` WHERE `u`.`b` IS NULL"},
+		{"DELETE QUICK FROM t AS x WHERE x.a < 1 ORDER BY x.a LIMIT 5 RETURNING *",
+			"DELETE QUICK FROM `t` AS `x` WHERE `x`.`a` < 1 ORDER BY `x`.`a` LIMIT 5 RETURNING *"},
+		{"DELETE t1, db.t2.* FROM t1 INNER JOIN db.t2 ON t1.id = t2.id",
+			"DELETE `t1`, `db`.`t2` FROM `t1` INNER JOIN `db`.`t2` ON `t1`.`id` = `t2`.`id`"},
+		{"DELETE FROM t1.* USING t1 LEFT JOIN t2 USING (id) WHERE t2.id IS NULL",
+			"DELETE FROM `t1` USING `t1` LEFT JOIN `t2` USING (`id`) WHERE `t2`.`id` IS NULL"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Not expecting error parsing %q but got: %s", tt.query, err.Error())
+			continue
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, stmt.String())
+		}
+
+		again, err := Parse(stmt.String())
+		if err != nil {
+			t.Errorf("Not expecting error parsing %q but got: %s", stmt.String(), err.Error())
+		} else if !reflect.DeepEqual(stmt, again) {
+			t.Errorf("Expected %q to parse back to the same syntax tree", stmt.String())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		msg   string
+	}{
+		{"SELECT a FROM", "Syntax error at end of query, line 1, column 14: expected table name\n\tSELECT a FROM\n\t             ^"},
+		{"SELECT a,\nFROM t", "Syntax error \"FROM\" at line 2, column 1: expected expression\n\tFROM t\n\t^"},
+		{"SELECT (a FROM t", "Syntax error \"FROM\" at line 1, column 11: expected )\n\tSELECT (a FROM t\n\t          ^"},
+		{"SELECT a FROM t UNION SELECT b FROM u", "Syntax error \"UNION\" at line 1, column 17: expected end of statement\n\tSELECT a FROM t UNION SELECT b FROM u\n\t                ^"},
+		{"SELECT a FROM t LOCK IN t", "Syntax error \"IN\" at line 1, column 22: expected IN SHARE MODE\n\tSELECT a FROM t LOCK IN t\n\t                     ^"},
+		{"SELECT a + INTERVAL 1 DAYS", "Syntax error \"DAYS\" at line 1, column 23: expected interval unit\n\tSELECT a + INTERVAL 1 DAYS\n\t                      ^"},
+		{"SELECT CONVERT(a)", "Syntax error \")\" at line 1, column 17: expected , or USING\n\tSELECT CONVERT(a)\n\t                ^"},
+		{"SELECT GROUP_CONCAT(a SEPARATOR b)", "Syntax error \"b\" at line 1, column 33: expected separator string\n\tSELECT GROUP_CONCAT(a SEPARATOR b)\n\t                                ^"},
+		{"DROP TABLE t", "Syntax error \"DROP\" at line 1, column 1: expected statement\n\tDROP TABLE t\n\t^"},
+		{"INSERT INTO t (a) SET a = 1", "Syntax error \"SET\" at line 1, column 19: expected VALUES or SELECT\n\tINSERT INTO t (a) SET a = 1\n\t                  ^"},
+		{"REPLACE INTO t VALUES (1) ON DUPLICATE KEY UPDATE a = 1", "Syntax error \"ON DUPLICATE KEY UPDATE\" at line 1, column 27: expected end of statement\n\tREPLACE INTO t VALUES (1) ON DUPLICATE KEY UPDATE a = 1\n\t                          ^"},
+		{"UPDATE t SET a WHERE b", "Syntax error \"WHERE\" at line 1, column 16: expected =\n\tUPDATE t SET a WHERE b\n\t               ^"},
+		{"SELECT /*!50000 SQL_NO_CACHE */ a FROM t", "Syntax error \"/*!50000 SQL_NO_CACHE */\" at line 1, column 8: versioned comments are not supported\n\tSELECT /*!50000 SQL_NO_CACHE */ a FROM t\n\t       ^"},
+		{"DELETE t1 WHERE a = 1", "Syntax error \"WHERE\" at line 1, column 11: expected FROM\n\tDELETE t1 WHERE a = 1\n\t          ^"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if !errors.Is(err, ErrSyntax) {
+			t.Errorf("Expected syntax error parsing %q, got %v", tt.query, err)
+			continue
+		}
+		if err.Error() != tt.msg {
+			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", tt.msg, err.Error())
+		}
+	}
+
+	if _, err := Parse("SELECT 'a"); !errors.Is(err, ErrUnterminated) {
+		t.Errorf("Expected unterminated token error, got %v", err)
+	}
+}
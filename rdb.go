@@ -5,4 +5,20 @@ import "database/sql"
 // Rdb is the package access point for relational database operations.
 type Rdb struct {
 	Db *sql.DB
+
+	// Registry holds the models available to this Rdb. When nil the default
+	// registry populated by the package level Register function is used.
+	Registry *Registry
+
+	// Retry sets how InTx runs again a transaction failing on a deadlock or
+	// a lock wait timeout, by default it is run once.
+	Retry RetryPolicy
+}
+
+// registry returns the Registry models are resolved against.
+func (r *Rdb) registry() *Registry {
+	if r.Registry == nil {
+		return defaultRegistry
+	}
+	return r.Registry
 }
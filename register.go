@@ -3,18 +3,86 @@ package rdb
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 )
 
-// db maps a database to a map of tables and a
-// table that contains a slice of column definitions.
-var dbMap = make(map[string]map[string][]column)
+// Registry holds the database/table/column maps built from registered model
+// structs. A Registry is safe for concurrent use by multiple goroutines, so
+// models may be registered from any number of init paths or goroutines.
+//
+// The zero value is not usable, create a Registry with NewRegistry. Separate
+// registries are fully isolated from each other and from the default registry
+// used by the package level Register function.
+type Registry struct {
+	mu sync.RWMutex
+
+	// dbMap maps a database to a map of tables and a
+	// table that contains a slice of column definitions.
+	dbMap map[string]map[string]*table
+
+	// modMap maps a model struct type to a corresponding database/table pair.
+	modMap map[reflect.Type]*table
+
+	// graph maps a table to its resolved foreign keys, built by Validate and
+	// discarded whenever a model is registered.
+	graph map[*table][]foreignKey
+
+	// selects caches the analyzed result columns of SELECT queries by query,
+	// discarded whenever a model is registered.
+	selects map[string][]ResultColumn
 
-// model maps a model struct to a corresponding database/table pair.
-var modMap = make(map[string][]string)
+	// gen counts the models registered, so an analysis made while a model
+	// was registered is not cached.
+	gen uint64
+
+	// sealed is set by Seal, no more models may be registered once set.
+	sealed bool
+}
+
+// table describes a registered model and the database table it maps to.
+// A table is never modified once registered, re-registering a model replaces
+// it instead.
+type table struct {
+	model  reflect.Type // Model struct type mapped to the table
+	dbName string       // Database the table belongs to
+	name   string       // Table name in the database
+	cols   []column     // Columns in model field order
+	pk     []int        // Indexes into cols of the primary key columns in key order
+}
+
+// defaultRegistry backs the package level Register function and any Rdb that
+// has not been given a Registry of its own.
+var defaultRegistry = NewRegistry()
+
+// NewRegistry creates and returns a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		dbMap:  make(map[string]map[string]*table),
+		modMap: make(map[reflect.Type]*table),
+	}
+}
+
+// DefaultRegistry returns the package default Registry used by Register.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register scans a model struct and adds its database/table/column map to the
+// default Registry. See Registry.Register for the db struct tag format.
+func Register(model interface{}) error {
+	return defaultRegistry.Register(model)
+}
 
 // Register scans a model struct and builds a database/table/column map from
-// the db struct tags.
+// the db struct tags. Every problem found in the model is reported at once in
+// a *RegistrationError, see FieldError for the details of a problem. The model
+// may be a struct value or a pointer to one and is registered by its full
+// type, so models with the same name declared in different packages do not
+// collide. Registering a different model type for a database/table pair that
+// is already registered is an error, registering the same model type again
+// replaces its previous definition.
 //
 // All RDB model structs *MUST* define ONE EACH of:
 //  - table=tbl_name which maps the name of the table that corresponds with
@@ -40,43 +108,87 @@ var modMap = make(map[string][]string)
 // Here the field is anonymous and set to type bool to reduce the impact on
 // struct size.
 //
+// Fields of anonymous embedded structs are flattened into the model, so common
+// columns can be declared once in a base struct:
+//
+// type Timestamps struct {
+// 		Created time.Time `db:"col=created_at"`
+// 		Updated time.Time `db:"col=updated_at"`
+// }
+//
+// type RDBModel struct {
+// 		Timestamps
+// 		ID int `db:"database=my_database,table=my_table,col=id,pk,ai"`
+// }
+//
+// Named struct fields, and embedded structs whose columns need to be told
+// apart, are flattened with the prefix=col_prefix tag which prepends the
+// prefix to every column name of the nested struct. The tag may not be
+// combined with other options:
+//
+// type RDBModel struct {
+// 		ID      int     `db:"database=my_database,table=my_table,col=id,pk,ai"`
+// 		Billing Address `db:"prefix=billing_"`
+// }
+//
+// Nested fields are referenced by their path, e.g. "Billing.Street", and
+// column names must be unique across the flattened model. Column fields and
+// prefixed named struct fields must be exported so rows can be scanned into
+// them, fields of unexported embedded structs are promoted and may be used.
+//
 // Optional flags can be set to define column metaedata:
-//  - pk sets a boolean that flags a column as a primary key
+//  - pk sets a boolean that flags a column as a primary key. Several columns
+//    may be flagged to form a composite primary key, ordered as declared in
+//    the struct. Use pk=position instead, starting at 1, on every key column
+//    when the key order differs from the field order.
 //  - ai sets a boolean that flags a column as being an auto-increment column
-//    which will let RDB auto-fetch IDs when making insert statements
+//    which will let RDB auto-fetch IDs when making insert statements. Only one
+//    column per model may be flagged ai.
 //  - null sets a boolean that flags whether a column will accept a null value or not
 //  - fkmap=ColName.Model.Field maps a struct field that represents an embedded RDB
 //    model type defined outside of the model being mapped and tells RDB which
 //    column in the table represents the related entity foreign key. fk allows
-//    helper functions to load related entities.
-func Register(model interface{}) error {
-	r := reflect.TypeOf(model)
-	if r.Kind() != reflect.Struct {
-		return fmt.Errorf("Register can only be called on struct types. Called on %T", model)
+//    helper functions to load related entities. ColName must be declared before
+//    the fkmap, the referenced Model.Field is resolved by Registry.Validate
+//    once every model has been registered.
+func (reg *Registry) Register(model interface{}) error {
+	r := modelType(model)
+	if r == nil {
+		errs := &RegistrationError{Model: fmt.Sprintf("%T", model)}
+		errs.add(ErrNotStruct, "", "", "Register can only be called on struct types. Called on %T", model)
+		return errs
 	}
 
 	// Read every field in struct for database tags
 	// Ignore fields that do not have db tags
 	modelName := r.Name()
-	nf := r.NumField()
+	if modelName == "" {
+		modelName = r.String()
+	}
+	errs := &RegistrationError{Model: modelName}
+	fields := flattenFields(errs, r, nil, "", "")
+
+	nf := len(fields)
 	cols := make([]column, 0, nf)
 	colCheck := make(map[string]bool)
-	var tblName, dbName string
+	var tblName, dbName, aiField string
+	var dbDeclared, tblDeclared bool
+	fieldErrs := len(errs.Errors) > 0
 	for i := 0; i < nf; i++ {
-		f := r.Field(i)
-		tag, ok := f.Tag.Lookup("db")
-		if !ok {
-			continue
-		}
+		f := fields[i]
+		tag := f.Tag.Get("db")
 
 		col := column{}
 		col.colType = f.Type.Kind()
 		col.fieldName = f.Name
+		col.index = f.Index
 		colNameSet := false
 		dbNameSet := false
 		tblNameSet := false
+		problems := len(errs.Errors)
 
-		// Parse database tag for all options
+		// Parse database tag for all options, problems are collected and the
+		// next option is parsed
 		parts := strings.Split(tag, ",")
 		for _, s := range parts {
 			s = strings.TrimSpace(s)
@@ -85,41 +197,47 @@ func Register(model interface{}) error {
 			case len(s) >= 6 && "fkmap=" == s[0:6]:
 				cnt := strings.Count(s, ".")
 				if cnt != 2 {
-					return fmt.Errorf(
+					errs.add(ErrBadFKMap, f.Name, s,
 						`Foreign-key tag validation error on "%s.%s": Format is "fkmap=ColName.Model.Field" but "%s" given`,
 						modelName, f.Name, tag)
+					continue
 				}
 
 				idx := strings.Index(s, ".")
-				_, ok := colCheck[s[6:idx]]
+				_, ok := colCheck[f.prefix+s[6:idx]]
 				if !ok {
-					return fmt.Errorf(
+					errs.add(ErrBadFKMap, f.Name, s,
 						`Foreign-key column "%s" has not been registered for "%s.%s". "%s" MUST preceed this field in the struct definition.`,
 						s[6:idx], modelName, f.Name, s[6:idx])
+					continue
 				}
 
-				col.colRelation = s[6:]
+				col.colRelation = f.prefix + s[6:]
 				col.fk = true
 
 			// Table name definition, on PK column by convention
 			case len(s) >= 6 && "table=" == s[0:6]:
+				tblDeclared = true
 				if len(s[6:]) == 0 {
-					return fmt.Errorf(
+					errs.add(ErrInvalidName, f.Name, s,
 						`Table name tag validation error on "%s.%s": Format is "table=table_name"`,
 						modelName, f.Name)
+					continue
 				}
 
 				if tblName != "" {
-					return fmt.Errorf(
+					errs.add(ErrRedeclared, f.Name, s,
 						`Table name cannot be redeclared as "%s" in "%s.%s": already defined as "%s"`,
 						s[6:], modelName, f.Name, tblName)
+					continue
 				}
 
 				ind := strings.Index(s, ".")
 				if ind >= 0 {
-					return fmt.Errorf(
+					errs.add(ErrInvalidName, f.Name, s,
 						`Table name validation error on "%s.%s": Name may not include '.', "%s" given`,
 						modelName, f.Name, s[6:])
+					continue
 				}
 
 				tblName = s[6:]
@@ -127,23 +245,27 @@ func Register(model interface{}) error {
 
 			// Database definition
 			case len(s) >= 9 && s[0:9] == "database=":
+				dbDeclared = true
 				if len(s[9:]) == 0 {
-					return fmt.Errorf(
+					errs.add(ErrInvalidName, f.Name, s,
 						`Database name tag validation error on "%s.%s": Format is "database=db_name"`,
 						modelName, f.Name)
+					continue
 				}
 
 				if dbName != "" {
-					return fmt.Errorf(
+					errs.add(ErrRedeclared, f.Name, s,
 						`Database name cannot be redeclared "%s" in "%s.%s": already defined as "%s"`,
 						s[9:], modelName, f.Name, dbName)
+					continue
 				}
 
 				ind := strings.Index(s, ".")
 				if ind >= 0 {
-					return fmt.Errorf(
+					errs.add(ErrInvalidName, f.Name, s,
 						`Database name validation error on "%s.%s": May not include '.' in name, "%s" given`,
 						modelName, f.Name, s[9:])
+					continue
 				}
 
 				dbName = s[9:]
@@ -151,12 +273,33 @@ func Register(model interface{}) error {
 
 			// Auto-increment definition
 			case "ai" == s:
+				if aiField != "" {
+					errs.add(ErrRedeclared, f.Name, s,
+						`Auto-increment cannot be redeclared on "%s.%s": already declared on "%s.%s"`,
+						modelName, f.Name, modelName, aiField)
+					continue
+				}
+
 				col.ai = true
+				aiField = f.Name
 
 			// Primary-key definition
 			case "pk" == s:
 				col.pk = true
 
+			// Primary-key definition with the column position in a composite key
+			case len(s) >= 3 && s[0:3] == "pk=":
+				n, err := strconv.Atoi(s[3:])
+				if err != nil || n < 1 {
+					errs.add(ErrBadPrimaryKey, f.Name, s,
+						`Primary key tag validation error on "%s.%s": Format is "pk=position" with a position starting at 1, "%s" given`,
+						modelName, f.Name, s)
+					continue
+				}
+
+				col.pk = true
+				col.pkOrder = n
+
 			// Is Nullable definition
 			case "null" == s:
 				col.null = true
@@ -164,39 +307,59 @@ func Register(model interface{}) error {
 			// Column name definition
 			case len(s) >= 4 && s[0:4] == "col=":
 				if colNameSet {
-					return fmt.Errorf(
+					errs.add(ErrRedeclared, f.Name, s,
 						`Cannot use "%s" for column name, "%s" already declared for "%s.%s"`,
 						s[4:], col.colName, modelName, f.Name)
+					continue
 				}
 
 				if len(s[4:]) == 0 {
-					return fmt.Errorf(
+					errs.add(ErrInvalidName, f.Name, s,
 						`Column name validation error on "%s.%s": Format is "col=col_name"`,
 						modelName, f.Name)
+					continue
 				}
 
-				if _, ok := colCheck[s[4:]]; ok {
-					return fmt.Errorf(
+				name := f.prefix + s[4:]
+				if _, ok := colCheck[name]; ok {
+					errs.add(ErrDuplicateColumn, f.Name, s,
 						`Duplicate column name "%s" on "%s.%s", column was already declared on another filed`,
-						s[4:], modelName, f.Name)
+						name, modelName, f.Name)
+					continue
 				}
 
-				col.colName = s[4:]
-				colCheck[s[4:]] = true
+				col.colName = name
+				colCheck[name] = true
 				colNameSet = true
 
 			// This is an error in every case.
 			default:
-				return fmt.Errorf(
+				errs.add(ErrBadTag, f.Name, s,
 					`db tag validation error: inspect "%s.%s" for empty db tag values`,
 					modelName, f.Name)
 			}
 		}
 
+		// A field with invalid options is not reported again for a missing
+		// column name, the invalid option may have been meant to declare it
+		if len(errs.Errors) > problems {
+			fieldErrs = true
+			continue
+		}
+
 		if colNameSet == false && (!dbNameSet && !tblNameSet) {
-			return fmt.Errorf(
+			errs.add(ErrMissingColumn, f.Name, "",
 				`db tag validation error, column name was not found for "%s.%s" in tag: %s`,
 				modelName, f.Name, tag)
+			fieldErrs = true
+			continue
+		}
+
+		if colNameSet && !f.IsExported() {
+			errs.add(ErrUnexported, f.Name, tag,
+				`Unexported field "%s.%s" cannot be mapped to a column, column fields must be exported`, modelName, f.Name)
+			fieldErrs = true
+			continue
 		}
 
 		if colNameSet {
@@ -204,26 +367,247 @@ func Register(model interface{}) error {
 		}
 	}
 
-	if len(cols) == 0 {
-		return fmt.Errorf("No columns were defined in %s struct", modelName)
+	if len(cols) == 0 && !fieldErrs {
+		errs.add(ErrNoColumns, "", "", "No columns were defined in %s struct", modelName)
+	}
+
+	if dbName == "" && !dbDeclared {
+		errs.add(ErrMissingDatabase, "", "", "Database name was not defined in %s struct.", modelName)
+	}
+
+	if tblName == "" && !tblDeclared {
+		errs.add(ErrMissingTable, "", "", "Table namne was not defined in %s struct.", modelName)
+	}
+
+	pk := primaryKey(errs, cols)
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.sealed {
+		errs.add(ErrSealed, "", "", "Cannot register %s struct, the registry has been sealed", modelName)
+		return errs
 	}
 
-	if dbName == "" {
-		return fmt.Errorf("Database name was not defined in %s struct.", modelName)
+	if t, ok := reg.dbMap[dbName][tblName]; ok && t.model != r {
+		errs.add(ErrTableConflict, "", "",
+			`Table "%s.%s" is already registered to model "%s", cannot register "%s"`,
+			dbName, tblName, typeName(t.model), typeName(r))
+		return errs
 	}
 
-	if tblName == "" {
-		return fmt.Errorf("Table namne was not defined in %s struct.", modelName)
+	if _, ok := reg.dbMap[dbName]; ok == false {
+		reg.dbMap[dbName] = make(map[string]*table)
 	}
 
-	if _, ok := dbMap[dbName]; ok == false {
-		dbMap[dbName] = make(map[string][]column)
+	// A model registered again may have moved to another database/table pair
+	if t, ok := reg.modMap[r]; ok {
+		delete(reg.dbMap[t.dbName], t.name)
 	}
 
-	// Everything okay, map model name to db/table for quick Lookup
+	// Everything okay, map model type to db/table for quick Lookup
 	// Add table definition to database map
-	modMap[modelName] = []string{dbName, tblName}
-	dbMap[dbName][tblName] = cols
+	t := &table{model: r, dbName: dbName, name: tblName, cols: cols, pk: pk}
+	reg.modMap[r] = t
+	reg.dbMap[dbName][tblName] = t
+	reg.graph = nil
+	reg.selects = nil
+	reg.gen++
 
 	return nil
 }
+
+// primaryKey validates the primary key columns of a model and returns their
+// indexes in cols in key order, setting the key position of every column.
+// Columns flagged with a bare pk are ordered by field declaration, pk=N sets
+// the position of a column in a composite key explicitly. A model must use
+// either form for all of its key columns.
+func primaryKey(errs *RegistrationError, cols []column) []int {
+	var pk []int
+	ordered := 0
+	for i, c := range cols {
+		if !c.pk {
+			continue
+		}
+		pk = append(pk, i)
+		if c.pkOrder > 0 {
+			ordered++
+		}
+	}
+
+	if ordered == 0 {
+		for n, i := range pk {
+			cols[i].pkOrder = n + 1
+		}
+		return pk
+	}
+
+	modelName := errs.Model
+	if ordered != len(pk) {
+		errs.add(ErrBadPrimaryKey, "", "",
+			`Primary key columns of %s struct must either all or none declare a key position with "pk=position"`,
+			modelName)
+		return nil
+	}
+
+	keys := make([]int, len(pk))
+	problems := len(errs.Errors)
+	for _, i := range pk {
+		n := cols[i].pkOrder
+		segment := "pk=" + strconv.Itoa(n)
+		if n > len(pk) {
+			errs.add(ErrBadPrimaryKey, cols[i].fieldName, segment,
+				`Primary key position %d on "%s.%s" is out of range, %s struct declares %d primary key columns`,
+				n, modelName, cols[i].fieldName, modelName, len(pk))
+			continue
+		}
+
+		if keys[n-1] != 0 {
+			errs.add(ErrBadPrimaryKey, cols[i].fieldName, segment,
+				`Primary key position %d on "%s.%s" was already declared on "%s.%s"`,
+				n, modelName, cols[i].fieldName, modelName, cols[keys[n-1]-1].fieldName)
+			continue
+		}
+		keys[n-1] = i + 1
+	}
+
+	if len(errs.Errors) > problems {
+		return nil
+	}
+
+	for n := range keys {
+		keys[n]--
+	}
+	return keys
+}
+
+// modelField is a tagged struct field of a model. Fields of embedded and
+// nested structs are flattened into the model, Name holds the field path from
+// the model, e.g. "Address.Street", and Index the index sequence for
+// reflect.Value.FieldByIndex.
+type modelField struct {
+	reflect.StructField
+	prefix string // Column name prefix of the enclosing nested structs
+}
+
+// flattenFields returns every db tagged field of struct type r, descending
+// into embedded and nested structs. index, path and prefix are the index
+// sequence, field path and column prefix of r within the model being
+// registered. Invalid prefix tags and unexported nested structs are recorded
+// in errs and their fields skipped.
+//
+// Anonymous embedded structs without a db tag are flattened into the parent
+// as if their fields were declared on it. Embedded or named struct fields with
+// a db:"prefix=pre_" tag are flattened as well, with the prefix prepended to
+// the column names of their fields.
+func flattenFields(errs *RegistrationError, r reflect.Type, index []int, path, prefix string) []modelField {
+	fields := make([]modelField, 0, r.NumField())
+	for i := 0; i < r.NumField(); i++ {
+		f := r.Field(i)
+		f.Index = append(append([]int{}, index...), i)
+		name := f.Name
+		if path != "" {
+			name = path + "." + f.Name
+		}
+
+		tag, ok := f.Tag.Lookup("db")
+		nestedPrefix, nested, err := nestedStruct(f, tag, ok)
+		if err != nil {
+			errs.add(ErrBadPrefix, name, tag,
+				`Column prefix tag validation error on "%s.%s": %s`, errs.Model, name, err.Error())
+			continue
+		}
+
+		// Fields of unexported nested structs can not be set, those of
+		// unexported embedded structs are promoted and can
+		if nested && !f.Anonymous && !f.IsExported() {
+			errs.add(ErrUnexported, name, tag,
+				`Unexported field "%s.%s" cannot be mapped to columns, prefixed structs must be exported`, errs.Model, name)
+			continue
+		}
+
+		if nested {
+			// Embedded fields are promoted, nested named fields are not
+			nestedPath := name
+			if f.Anonymous {
+				nestedPath = path
+			}
+
+			fields = append(fields, flattenFields(errs, f.Type, f.Index, nestedPath, prefix+nestedPrefix)...)
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		f.Name = name
+		fields = append(fields, modelField{StructField: f, prefix: prefix})
+	}
+
+	return fields
+}
+
+// nestedStruct reports whether a struct field is to be flattened into the
+// model and returns its column name prefix.
+func nestedStruct(f reflect.StructField, tag string, tagged bool) (string, bool, error) {
+	if !tagged {
+		return "", f.Anonymous && f.Type.Kind() == reflect.Struct, nil
+	}
+
+	parts := strings.Split(tag, ",")
+	prefixed := false
+	for _, s := range parts {
+		if strings.HasPrefix(strings.TrimSpace(s), "prefix=") {
+			prefixed = true
+		}
+	}
+
+	if !prefixed {
+		return "", false, nil
+	}
+
+	if len(parts) != 1 {
+		return "", false, fmt.Errorf(`Format is "prefix=col_prefix" with no other options but "%s" given`, tag)
+	}
+	s := strings.TrimSpace(parts[0])
+
+	if f.Type.Kind() != reflect.Struct {
+		return "", false, fmt.Errorf("Prefix may only be used on struct fields, %s given", f.Type.Kind())
+	}
+
+	return s[7:], true, nil
+}
+
+// lookup returns the registered table definition of a model type.
+func (reg *Registry) lookup(model reflect.Type) (*table, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	t, ok := reg.modMap[model]
+	return t, ok
+}
+
+// modelType returns the struct type of a model given as a struct value or a
+// pointer to a struct, or nil if model is neither.
+func modelType(model interface{}) reflect.Type {
+	r := reflect.TypeOf(model)
+	if r != nil && r.Kind() == reflect.Ptr {
+		r = r.Elem()
+	}
+	if r == nil || r.Kind() != reflect.Struct {
+		return nil
+	}
+	return r
+}
+
+// typeName returns the package qualified name of a model type.
+func typeName(r reflect.Type) string {
+	if r.Name() == "" || r.PkgPath() == "" {
+		return r.String()
+	}
+	return r.PkgPath() + "." + r.Name()
+}
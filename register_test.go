@@ -1,14 +1,40 @@
 package rdb
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 )
 
 func reset() {
-	dbMap = make(map[string]map[string][]column)
-	modMap = make(map[string][]string)
+	defaultRegistry = NewRegistry()
+}
+
+// expectProblem fails the test unless e is a *RegistrationError holding a
+// problem of the given kind with message m.
+func expectProblem(t *testing.T, e error, kind error, m string) {
+	t.Helper()
+	var re *RegistrationError
+	if !errors.As(e, &re) {
+		t.Fatalf("Expected a *RegistrationError, got %T: %v", e, e)
+	}
+
+	if !errors.Is(e, kind) {
+		t.Errorf("Expected error to be of kind %q, got: %s", kind, e.Error())
+	}
+
+	for _, fe := range re.Errors {
+		if fe.Error() == m {
+			if !errors.Is(fe, kind) {
+				t.Errorf("Expected:\n'%s'\nto be of kind %q, got %q", m, kind, fe.Kind)
+			}
+			return
+		}
+	}
+	t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
 }
 
 func TestNotOnStructError(t *testing.T) {
@@ -19,9 +45,7 @@ func TestNotOnStructError(t *testing.T) {
 		t.Errorf("Expected error on registering non-struct value")
 	} else {
 		m := "Register can only be called on struct types. Called on bool"
-		if e.Error() != m {
-			t.Errorf("Expected error message '%s'\nGot error message '%s' instead", m, e.Error())
-		}
+		expectProblem(t, e, ErrNotStruct, m)
 	}
 }
 
@@ -33,9 +57,7 @@ func TestOnEmptyStructError(t *testing.T) {
 		t.Errorf("Expecting error on TestOnEmptyStructError")
 	} else {
 		m := "No columns were defined in empty struct"
-		if e.Error() != m {
-			t.Errorf("Expected error message '%s'\nGot error message '%s' instead", m, e.Error())
-		}
+		expectProblem(t, e, ErrNoColumns, m)
 	}
 }
 
@@ -49,9 +71,7 @@ func TestMissingDatabaseName(t *testing.T) {
 		t.Errorf("Expecting error on TestMissingDatabaseName")
 	} else {
 		m := "Database name was not defined in missingDB struct."
-		if e.Error() != m {
-			t.Errorf("Expected error message '%s'\nGot error message '%s' instead", m, e.Error())
-		}
+		expectProblem(t, e, ErrMissingDatabase, m)
 	}
 }
 
@@ -65,9 +85,7 @@ func TestMissingTableName(t *testing.T) {
 		t.Errorf("Expecting error on TestMissingTableName")
 	} else {
 		m := "Table namne was not defined in missingTbl struct."
-		if e.Error() != m {
-			t.Errorf("Expected error message '%s'\nGot error message '%s' instead", m, e.Error())
-		}
+		expectProblem(t, e, ErrMissingTable, m)
 	}
 }
 
@@ -81,9 +99,7 @@ func TestEmptyDBTags(t *testing.T) {
 		t.Fail()
 	} else {
 		m := `db tag validation error: inspect "emptyDBTag.ID" for empty db tag values`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrBadTag, m)
 	}
 }
 
@@ -97,9 +113,7 @@ func TestMissingColName(t *testing.T) {
 		t.Errorf("Expecting error on TestMissingColName")
 	} else {
 		m := `db tag validation error, column name was not found for "missingCol.ID" in tag: pk,ai`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrMissingColumn, m)
 	}
 }
 
@@ -114,9 +128,7 @@ func TestDuplicateColNameError(t *testing.T) {
 		t.Errorf("Expecting error on TestDuplicateColNameError")
 	} else {
 		m := `Duplicate column name "baz" on "duplicateCol.DUP", column was already declared on another filed`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrDuplicateColumn, m)
 	}
 }
 
@@ -130,9 +142,7 @@ func TestColumnFormatError(t *testing.T) {
 		t.Errorf("Expecting error on TestColumnFormatError")
 	} else {
 		m := `Column name validation error on "badColumnFormat.ID": Format is "col=col_name"`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrInvalidName, m)
 	}
 }
 
@@ -146,9 +156,7 @@ func TestColumnNameAlreadySet(t *testing.T) {
 		t.Errorf("Expecting error on TestColumnNameAlreadySet")
 	} else {
 		m := `Cannot use "bang" for column name, "foo" already declared for "colNameAlreadySet.ID"`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrRedeclared, m)
 	}
 }
 
@@ -162,9 +170,7 @@ func TestPeriodNotAllowedInDatabaseName(t *testing.T) {
 		t.Errorf("Expecting error on TestPeriodNotAllowedInDatabaseName")
 	} else {
 		m := `Database name validation error on "databaseNameWithPeriod.ID": May not include '.' in name, "bar.baz" given`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrInvalidName, m)
 	}
 }
 
@@ -178,9 +184,7 @@ func TestDatabaseReDeclaredError(t *testing.T) {
 		t.Errorf("Expecting error on TestDatabaseReDeclaredError")
 	} else {
 		m := `Database name cannot be redeclared "baz" in "databaseRedeclared.ID": already defined as "foo"`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrRedeclared, m)
 	}
 }
 
@@ -194,9 +198,7 @@ func TestDatabaseNameValidationError(t *testing.T) {
 		t.Errorf("Expecting error on TestDatabaseNameValidationError")
 	} else {
 		m := `Database name tag validation error on "databaseNameValidation.ID": Format is "database=db_name"`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrInvalidName, m)
 	}
 }
 
@@ -210,9 +212,7 @@ func TestPeriodNotAllowedInTableName(t *testing.T) {
 		t.Errorf("Expecting error on TestPeriodNotAllowedInTableName")
 	} else {
 		m := `Table name validation error on "tableNameWithPeriod.ID": Name may not include '.', "baz.bang" given`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrInvalidName, m)
 	}
 }
 
@@ -226,9 +226,7 @@ func TestTableReDeclaredError(t *testing.T) {
 		t.Errorf("Expecting error on TestDatabaseReDeclaredError")
 	} else {
 		m := `Table name cannot be redeclared as "bar" in "tableRedeclared.ID": already defined as "foo"`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrRedeclared, m)
 	}
 }
 
@@ -242,9 +240,7 @@ func TestTableNameValidationError(t *testing.T) {
 		t.Errorf("Expecting error on TestDatabaseNameValidationError")
 	} else {
 		m := `Table name tag validation error on "tableNameValidation.ID": Format is "table=table_name"`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrInvalidName, m)
 	}
 }
 
@@ -258,9 +254,7 @@ func TestForeignKeyMissingSeparator(t *testing.T) {
 		t.Errorf("Expecting error on TestForeignKeyMissingSeparator")
 	} else {
 		m := `Foreign-key tag validation error on "foreignKeyMissingSeparator.ID": Format is "fkmap=ColName.Model.Field" but "fkmap=MissingFieldSeparators" given`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrBadFKMap, m)
 	}
 }
 
@@ -274,9 +268,7 @@ func TestForeignKeyColumnNotFound(t *testing.T) {
 		t.Errorf("Expecting error on TestForeignKeyColumnNotFound")
 	} else {
 		m := `Foreign-key column "Poots" has not been registered for "foreignKeyColumnNotFound.ID". "Poots" MUST preceed this field in the struct definition.`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrBadFKMap, m)
 	}
 }
 
@@ -290,9 +282,7 @@ func TestForeignKeyValidationError(t *testing.T) {
 		t.Errorf("Expecting error on TestForeignKeyValidationError")
 	} else {
 		m := `Foreign-key tag validation error on "foriegnKeyValidationError.ID": Format is "fkmap=ColName.Model.Field" but "fkmap=MissingColName.MissingFieldSeparators" given`
-		if e.Error() != m {
-			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, e.Error())
-		}
+		expectProblem(t, e, ErrBadFKMap, m)
 	}
 }
 
@@ -317,21 +307,21 @@ func TestCanSetDbTableNameSeparately(t *testing.T) {
 	if e := Register(anonymousStructFieldConfig{}); e != nil {
 		t.Errorf("Wasn't expecting error on TestCanSetDbTableNameSeparately, but got: %s", e.Error())
 	}
-	if _, ok := modMap["anonymousStructFieldConfig"]; !ok {
+	if _, ok := defaultRegistry.modMap[reflect.TypeOf(anonymousStructFieldConfig{})]; !ok {
 		t.Errorf("Expected modMap to be set for anonymousStructFieldConfig")
 	}
 
-	if _, ok := dbMap["my_database"]; !ok {
+	if _, ok := defaultRegistry.dbMap["my_database"]; !ok {
 		t.Fatalf("Expected dbMap to be set for my_database")
 	}
 
-	col, ok := dbMap["my_database"]["my_table"]
+	tbl, ok := defaultRegistry.dbMap["my_database"]["my_table"]
 	if !ok {
-		t.Fatalf(`Expected dbMap["my_datgabase"] to be set for my_table`)
+		t.Fatalf(`Expected defaultRegistry.dbMap["my_datgabase"] to be set for my_table`)
 	}
 
-	if len(col) != 1 {
-		t.Fatalf("Expected exactly one column in table map, but got %d", len(col))
+	if len(tbl.cols) != 1 {
+		t.Fatalf("Expected exactly one column in table map, but got %d", len(tbl.cols))
 	}
 }
 
@@ -346,36 +336,37 @@ func TestAllTagsPass(t *testing.T) {
 		t.Errorf("Not expecting error on TestAllTagsPass but got: %s", e.Error())
 	}
 
-	if len(modMap) != 1 {
-		fmt.Printf("%+v", modMap)
-		t.Errorf("Expected length of modMap to be exactly 1, but got %d", len(modMap))
+	if len(defaultRegistry.modMap) != 1 {
+		fmt.Printf("%+v", defaultRegistry.modMap)
+		t.Errorf("Expected length of modMap to be exactly 1, but got %d", len(defaultRegistry.modMap))
 	}
 
-	mod, ok := modMap["allTagsPass"]
+	mod, ok := defaultRegistry.modMap[reflect.TypeOf(allTagsPass{})]
 	if !ok {
-		t.Errorf("Expecting a database.table map for allTagsPass, but not registered in modMap")
-	} else {
-		if len(mod) != 2 {
-			t.Errorf(`Expecting modMap for allTagsPass to be slice of two strings, but got: %+v`, mod)
-		}
+		t.Fatalf("Expecting a database.table map for allTagsPass, but not registered in modMap")
+	}
+
+	if mod.dbName != "foo" || mod.name != "bar" {
+		t.Errorf(`Expecting modMap for allTagsPass to map to foo.bar, but got: %s.%s`, mod.dbName, mod.name)
 	}
 
-	tblMap, ok := dbMap[mod[0]]
+	tblMap, ok := defaultRegistry.dbMap[mod.dbName]
 	if !ok {
-		t.Fatalf(`Expected to get a table map for datbase name %s, but not registered in dbMap`, mod[0])
+		t.Fatalf(`Expected to get a table map for datbase name %s, but not registered in dbMap`, mod.dbName)
 	}
 
 	if len(tblMap) != 1 {
-		t.Errorf(`Expected length of dbMap["%s"] to be exactly 1, but got %d`, mod[0], len(tblMap))
+		t.Errorf(`Expected length of defaultRegistry.dbMap["%s"] to be exactly 1, but got %d`, mod.dbName, len(tblMap))
 	}
 
-	colSlice, ok := tblMap[mod[1]]
+	tbl, ok := tblMap[mod.name]
 	if !ok {
-		t.Fatalf(`Expected to get a slice of columns for db %s, table %s, but not registered in dbMap`, mod[0], mod[1])
+		t.Fatalf(`Expected to get a slice of columns for db %s, table %s, but not registered in dbMap`, mod.dbName, mod.name)
 	}
 
+	colSlice := tbl.cols
 	if len(colSlice) != 2 {
-		t.Fatalf(`Expected length of []columns for db %s, table %s to be exactly 2, %d found`, mod[0], mod[1], len(colSlice))
+		t.Fatalf(`Expected length of []columns for db %s, table %s to be exactly 2, %d found`, mod.dbName, mod.name, len(colSlice))
 	}
 
 	// Foreign key column defined before foreign key map
@@ -426,3 +417,442 @@ func TestAllTagsPass(t *testing.T) {
 		t.Errorf(`Expected column null to be true, but got false`)
 	}
 }
+
+func TestRegistriesAreIsolated(t *testing.T) {
+	defer reset()
+	type isolatedModel struct {
+		ID int `db:"database=foo,table=bar,col=id"`
+	}
+	reg := NewRegistry()
+	if e := reg.Register(isolatedModel{}); e != nil {
+		t.Fatalf("Not expecting error on TestRegistriesAreIsolated but got: %s", e.Error())
+	}
+
+	if _, ok := reg.lookup(reflect.TypeOf(isolatedModel{})); !ok {
+		t.Errorf("Expected isolatedModel to be registered in new registry")
+	}
+
+	if _, ok := defaultRegistry.lookup(reflect.TypeOf(isolatedModel{})); ok {
+		t.Errorf("Expected isolatedModel not to be registered in default registry")
+	}
+
+	db := &Rdb{}
+	if db.registry() != defaultRegistry {
+		t.Errorf("Expected Rdb without a Registry to use the default registry")
+	}
+
+	db.Registry = reg
+	if db.registry() != reg {
+		t.Errorf("Expected Rdb to use its own Registry when set")
+	}
+}
+
+func TestConcurrentRegister(t *testing.T) {
+	type concurrentA struct {
+		ID int `db:"database=foo,table=a,col=id"`
+	}
+	type concurrentB struct {
+		ID int `db:"database=foo,table=b,col=id"`
+	}
+	type concurrentC struct {
+		ID int `db:"database=bar,table=c,col=id"`
+	}
+
+	reg := NewRegistry()
+	models := []interface{}{concurrentA{}, concurrentB{}, concurrentC{}}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, m := range models {
+			wg.Add(2)
+			go func(m interface{}) {
+				defer wg.Done()
+				if e := reg.Register(m); e != nil {
+					t.Errorf("Not expecting error on TestConcurrentRegister but got: %s", e.Error())
+				}
+			}(m)
+			go func() {
+				defer wg.Done()
+				reg.lookup(reflect.TypeOf(concurrentA{}))
+			}()
+		}
+	}
+	wg.Wait()
+
+	if len(reg.modMap) != 3 {
+		t.Errorf("Expected exactly 3 registered models, but got %d", len(reg.modMap))
+	}
+
+	if len(reg.dbMap["foo"]) != 2 {
+		t.Errorf(`Expected exactly 2 tables in database "foo", but got %d`, len(reg.dbMap["foo"]))
+	}
+}
+
+func TestRegisterAcceptsPointer(t *testing.T) {
+	defer reset()
+	type pointerModel struct {
+		ID int `db:"database=foo,table=bar,col=id"`
+	}
+	if e := Register(&pointerModel{}); e != nil {
+		t.Fatalf("Not expecting error on TestRegisterAcceptsPointer but got: %s", e.Error())
+	}
+
+	if _, ok := defaultRegistry.lookup(reflect.TypeOf(pointerModel{})); !ok {
+		t.Errorf("Expected pointer registration to be keyed by the struct type")
+	}
+
+	var np *int
+	if e := Register(np); e == nil {
+		t.Errorf("Expected error on registering pointer to non-struct value")
+	}
+
+	if e := Register(nil); e == nil {
+		t.Errorf("Expected error on registering nil")
+	}
+}
+
+func TestSameNameModelsDoNotCollide(t *testing.T) {
+	defer reset()
+	first := func() interface{} {
+		type User struct {
+			ID int `db:"database=first,table=users,col=id"`
+		}
+		return User{}
+	}()
+	second := func() interface{} {
+		type User struct {
+			ID int `db:"database=second,table=users,col=id"`
+		}
+		return User{}
+	}()
+
+	if e := Register(first); e != nil {
+		t.Fatalf("Not expecting error registering first User but got: %s", e.Error())
+	}
+	if e := Register(second); e != nil {
+		t.Fatalf("Not expecting error registering second User but got: %s", e.Error())
+	}
+
+	if len(defaultRegistry.modMap) != 2 {
+		t.Errorf("Expected two distinct User models to be registered, got %d", len(defaultRegistry.modMap))
+	}
+}
+
+func TestTableConflictError(t *testing.T) {
+	defer reset()
+	type conflictA struct {
+		ID int `db:"database=foo,table=bar,col=id"`
+	}
+	type conflictB struct {
+		ID int `db:"database=foo,table=bar,col=id"`
+	}
+	if e := Register(conflictA{}); e != nil {
+		t.Fatalf("Not expecting error on TestTableConflictError but got: %s", e.Error())
+	}
+
+	e := Register(conflictB{})
+	if e == nil {
+		t.Fatalf("Expecting error registering a second model for the same table")
+	}
+
+	pkg := reflect.TypeOf(conflictA{}).PkgPath()
+	m := fmt.Sprintf(`Table "foo.bar" is already registered to model "%s.conflictA", cannot register "%s.conflictB"`, pkg, pkg)
+	expectProblem(t, e, ErrTableConflict, m)
+
+	// Registering the same model again is not a conflict
+	if e := Register(conflictA{}); e != nil {
+		t.Errorf("Not expecting error re-registering the same model but got: %s", e.Error())
+	}
+}
+
+type registerTimestamps struct {
+	Created string `db:"col=created_at"`
+	Updated string `db:"col=updated_at,null"`
+}
+
+type registerAddress struct {
+	Street string `db:"col=street"`
+	City   string `db:"col=city"`
+}
+
+func TestEmbeddedStructsAreFlattened(t *testing.T) {
+	defer reset()
+	type embeddedModel struct {
+		registerTimestamps
+		ID int `db:"database=foo,table=bar,col=id,pk"`
+	}
+	if e := Register(embeddedModel{}); e != nil {
+		t.Fatalf("Not expecting error on TestEmbeddedStructsAreFlattened but got: %s", e.Error())
+	}
+
+	tbl, _ := defaultRegistry.lookup(reflect.TypeOf(embeddedModel{}))
+	if len(tbl.cols) != 3 {
+		t.Fatalf("Expected 3 flattened columns, got %d", len(tbl.cols))
+	}
+
+	col := tbl.cols[1]
+	if col.fieldName != "Updated" || col.colName != "updated_at" || !col.null {
+		t.Errorf("Expected promoted Updated field mapped to nullable updated_at, got %s/%s", col.fieldName, col.colName)
+	}
+
+	if !reflect.DeepEqual(col.index, []int{0, 1}) {
+		t.Errorf("Expected Updated field index [0 1], got %v", col.index)
+	}
+}
+
+func TestNestedStructsArePrefixed(t *testing.T) {
+	defer reset()
+	type nestedModel struct {
+		ID       int             `db:"database=foo,table=bar,col=id,pk"`
+		Billing  registerAddress `db:"prefix=billing_"`
+		Shipping registerAddress `db:"prefix=shipping_"`
+		Ignored  registerAddress
+	}
+	if e := Register(nestedModel{}); e != nil {
+		t.Fatalf("Not expecting error on TestNestedStructsArePrefixed but got: %s", e.Error())
+	}
+
+	tbl, _ := defaultRegistry.lookup(reflect.TypeOf(nestedModel{}))
+	if len(tbl.cols) != 5 {
+		t.Fatalf("Expected 5 flattened columns, got %d", len(tbl.cols))
+	}
+
+	col := tbl.cols[4]
+	if col.fieldName != "Shipping.City" || col.colName != "shipping_city" {
+		t.Errorf("Expected Shipping.City mapped to shipping_city, got %s/%s", col.fieldName, col.colName)
+	}
+
+	if !reflect.DeepEqual(col.index, []int{2, 1}) {
+		t.Errorf("Expected Shipping.City field index [2 1], got %v", col.index)
+	}
+}
+
+func TestNestedForeignKeysArePrefixed(t *testing.T) {
+	defer reset()
+	type registerOwnerRef struct {
+		OwnerID int `db:"col=owner_id"`
+		Owner   int `db:"col=owner,fkmap=owner_id.User.ID"`
+	}
+	type nestedFKModel struct {
+		ID      int              `db:"database=foo,table=bar,col=id,pk"`
+		Creator registerOwnerRef `db:"prefix=creator_"`
+	}
+	if e := Register(nestedFKModel{}); e != nil {
+		t.Fatalf("Not expecting error on TestNestedForeignKeysArePrefixed but got: %s", e.Error())
+	}
+
+	tbl, _ := defaultRegistry.lookup(reflect.TypeOf(nestedFKModel{}))
+	col := tbl.cols[2]
+	if !col.fk || col.colRelation != "creator_owner_id.User.ID" {
+		t.Errorf("Expected Creator.Owner related through creator_owner_id, got %q", col.colRelation)
+	}
+}
+
+func TestFlattenedDuplicateColumnError(t *testing.T) {
+	defer reset()
+	type flattenedDuplicate struct {
+		registerAddress
+		City string `db:"database=foo,table=bar,col=city"`
+	}
+	var e error
+	if e = Register(flattenedDuplicate{}); e == nil {
+		t.Fatalf("Expecting error on TestFlattenedDuplicateColumnError")
+	}
+
+	m := `Duplicate column name "city" on "flattenedDuplicate.City", column was already declared on another filed`
+	expectProblem(t, e, ErrDuplicateColumn, m)
+}
+
+func TestPrefixTagValidationError(t *testing.T) {
+	defer reset()
+	type prefixWithOptions struct {
+		ID      int             `db:"database=foo,table=bar,col=id"`
+		Billing registerAddress `db:"prefix=billing_,null"`
+	}
+	var e error
+	if e = Register(prefixWithOptions{}); e == nil {
+		t.Errorf("Expecting error on TestPrefixTagValidationError")
+	} else {
+		m := `Column prefix tag validation error on "prefixWithOptions.Billing": Format is "prefix=col_prefix" with no other options but "prefix=billing_,null" given`
+		expectProblem(t, e, ErrBadPrefix, m)
+	}
+
+	type prefixOnNonStruct struct {
+		ID int `db:"database=foo,table=bar,prefix=id_"`
+	}
+	if e = Register(prefixOnNonStruct{}); e == nil {
+		t.Errorf("Expecting error on TestPrefixTagValidationError")
+	} else {
+		m := `Column prefix tag validation error on "prefixOnNonStruct.ID": Format is "prefix=col_prefix" with no other options but "database=foo,table=bar,prefix=id_" given`
+		expectProblem(t, e, ErrBadPrefix, m)
+	}
+}
+
+func TestUnexportedFieldError(t *testing.T) {
+	defer reset()
+	type unexportedColumn struct {
+		ID   int    `db:"database=foo,table=bar,col=id"`
+		name string `db:"col=name"`
+	}
+	var e error
+	if e = Register(unexportedColumn{}); e == nil {
+		t.Errorf("Expecting error on TestUnexportedFieldError")
+	} else {
+		m := `Unexported field "unexportedColumn.name" cannot be mapped to a column, column fields must be exported`
+		expectProblem(t, e, ErrUnexported, m)
+	}
+
+	type unexportedNested struct {
+		ID      int             `db:"database=foo,table=bar,col=id"`
+		billing registerAddress `db:"prefix=billing_"`
+	}
+	if e = Register(unexportedNested{}); e == nil {
+		t.Errorf("Expecting error on TestUnexportedFieldError")
+	} else {
+		m := `Unexported field "unexportedNested.billing" cannot be mapped to columns, prefixed structs must be exported`
+		expectProblem(t, e, ErrUnexported, m)
+	}
+
+	// Exported fields of unexported embedded structs are promoted and settable
+	type unexportedEmbedded struct {
+		ID int `db:"database=foo,table=bar,col=id,pk"`
+		registerAddress
+		registerTimestamps `db:"prefix=log_"`
+	}
+	if e = Register(unexportedEmbedded{}); e != nil {
+		t.Fatalf("Not expecting error on TestUnexportedFieldError but got: %s", e.Error())
+	}
+
+	tbl, _ := defaultRegistry.lookup(reflect.TypeOf(unexportedEmbedded{}))
+	m := unexportedEmbedded{}
+	v := reflect.ValueOf(&m).Elem()
+	for _, col := range tbl.cols {
+		if f := v.FieldByIndex(col.index); !f.CanSet() {
+			t.Errorf("Expected the %s column to be settable", col.colName)
+		}
+	}
+}
+
+func TestCompositePrimaryKeyOrder(t *testing.T) {
+	defer reset()
+	type declaredOrder struct {
+		TenantID int    `db:"database=foo,table=bar,col=tenant_id,pk"`
+		Name     string `db:"col=name"`
+		ID       int    `db:"col=id,pk"`
+	}
+	if e := Register(declaredOrder{}); e != nil {
+		t.Fatalf("Not expecting error on TestCompositePrimaryKeyOrder but got: %s", e.Error())
+	}
+
+	pk, _ := PrimaryKey(declaredOrder{})
+	if len(pk) != 2 || pk[0].Name() != "tenant_id" || pk[1].Name() != "id" {
+		t.Fatalf("Expected primary key (tenant_id, id), got %+v", pk)
+	}
+
+	if pk[1].KeyPosition() != 2 {
+		t.Errorf("Expected id key position 2, got %d", pk[1].KeyPosition())
+	}
+
+	type explicitOrder struct {
+		TenantID int `db:"database=foo,table=baz,col=tenant_id,pk=2"`
+		ID       int `db:"col=id,pk=1"`
+	}
+	if e := Register(explicitOrder{}); e != nil {
+		t.Fatalf("Not expecting error on TestCompositePrimaryKeyOrder but got: %s", e.Error())
+	}
+
+	pk, _ = PrimaryKey(explicitOrder{})
+	if len(pk) != 2 || pk[0].Name() != "id" || pk[1].Name() != "tenant_id" {
+		t.Errorf("Expected primary key (id, tenant_id), got %+v", pk)
+	}
+}
+
+func TestCompositePrimaryKeyErrors(t *testing.T) {
+	defer reset()
+	type badPosition struct {
+		ID int `db:"database=foo,table=bar,col=id,pk=0"`
+	}
+	type mixedPositions struct {
+		A int `db:"database=foo,table=bar,col=a,pk=1"`
+		B int `db:"col=b,pk"`
+	}
+	type duplicatePosition struct {
+		A int `db:"database=foo,table=bar,col=a,pk=1"`
+		B int `db:"col=b,pk=1"`
+	}
+	type positionOutOfRange struct {
+		A int `db:"database=foo,table=bar,col=a,pk=1"`
+		B int `db:"col=b,pk=3"`
+	}
+	type duplicateAutoIncrement struct {
+		A int `db:"database=foo,table=bar,col=a,pk,ai"`
+		B int `db:"col=b,ai"`
+	}
+
+	tests := []struct {
+		model interface{}
+		kind  error
+		m     string
+	}{
+		{badPosition{}, ErrBadPrimaryKey, `Primary key tag validation error on "badPosition.ID": Format is "pk=position" with a position starting at 1, "pk=0" given`},
+		{mixedPositions{}, ErrBadPrimaryKey, `Primary key columns of mixedPositions struct must either all or none declare a key position with "pk=position"`},
+		{duplicatePosition{}, ErrBadPrimaryKey, `Primary key position 1 on "duplicatePosition.B" was already declared on "duplicatePosition.A"`},
+		{positionOutOfRange{}, ErrBadPrimaryKey, `Primary key position 3 on "positionOutOfRange.B" is out of range, positionOutOfRange struct declares 2 primary key columns`},
+		{duplicateAutoIncrement{}, ErrRedeclared, `Auto-increment cannot be redeclared on "duplicateAutoIncrement.B": already declared on "duplicateAutoIncrement.A"`},
+	}
+	for _, test := range tests {
+		e := Register(test.model)
+		if e == nil {
+			t.Errorf("Expecting error registering %T", test.model)
+			continue
+		}
+		expectProblem(t, e, test.kind, test.m)
+	}
+}
+
+func TestRegistrationErrorsAreAggregated(t *testing.T) {
+	defer reset()
+	type manyProblems struct {
+		ID    int    `db:"database=foo,table=bar.baz,col=id,pk"`
+		Name  string `db:"col=id"`
+		Email string `db:"col=email,unique"`
+		Owner int    `db:"fkmap=owner_id.User.ID,col=owner"`
+	}
+	e := Register(manyProblems{})
+	if e == nil {
+		t.Fatalf("Expecting error on TestRegistrationErrorsAreAggregated")
+	}
+
+	var re *RegistrationError
+	if !errors.As(e, &re) {
+		t.Fatalf("Expected a *RegistrationError, got %T", e)
+	}
+
+	if re.Model != "manyProblems" {
+		t.Errorf("Expected model manyProblems, got %s", re.Model)
+	}
+
+	if len(re.Errors) != 4 {
+		t.Fatalf("Expected 4 problems, got %d:\n%s", len(re.Errors), e.Error())
+	}
+
+	kinds := []error{ErrInvalidName, ErrDuplicateColumn, ErrBadTag, ErrBadFKMap}
+	fields := []string{"ID", "Name", "Email", "Owner"}
+	segments := []string{"table=bar.baz", "col=id", "unique", "fkmap=owner_id.User.ID"}
+	for i, fe := range re.Errors {
+		if !errors.Is(fe, kinds[i]) {
+			t.Errorf("Expected problem %d to be of kind %q, got %q", i, kinds[i], fe.Kind)
+		}
+		if fe.Model != "manyProblems" || fe.Field != fields[i] || fe.Segment != segments[i] {
+			t.Errorf("Expected problem %d on manyProblems.%s %q, got %s.%s %q",
+				i, fields[i], segments[i], fe.Model, fe.Field, fe.Segment)
+		}
+	}
+
+	if !strings.HasPrefix(e.Error(), "4 errors registering manyProblems:\n") {
+		t.Errorf("Expected error message to list 4 problems, got:\n%s", e.Error())
+	}
+
+	if errors.Is(e, ErrMissingTable) {
+		t.Errorf("Expected an invalid table name not to be reported as missing")
+	}
+}
@@ -0,0 +1,307 @@
+package rdb
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// foreignKey is a resolved fkmap=ColName.Model.Field foreign-key map.
+type foreignKey struct {
+	from   *table // Table declaring the foreign-key map
+	col    column // Column declaring the foreign-key map
+	fkCol  column // Column of from holding the foreign key, ColName
+	to     *table // Referenced table, Model
+	refCol column // Referenced column, Model.Field
+}
+
+// ForeignKey is a read-only description of a resolved foreign-key map between
+// two registered models.
+type ForeignKey struct {
+	fk foreignKey
+}
+
+// Model returns the model declaring the foreign-key map.
+func (f ForeignKey) Model() Model {
+	return Model{f.fk.from}
+}
+
+// Column returns the column of the model holding the foreign key.
+func (f ForeignKey) Column() Column {
+	return Column{f.fk.fkCol}
+}
+
+// References returns the model referenced by the foreign key.
+func (f ForeignKey) References() Model {
+	return Model{f.fk.to}
+}
+
+// ReferencedColumn returns the column referenced by the foreign key.
+func (f ForeignKey) ReferencedColumn() Column {
+	return Column{f.fk.refCol}
+}
+
+// Validate resolves the fkmap=ColName.Model.Field foreign-key map of every
+// registered model and checks the relationship graph they form. Model is
+// matched against the type names of the registered models, preferring a model
+// declared in the same package as the referencing model when the name is
+// ambiguous, and Field against the field paths of the matched model.
+//
+// Every problem is reported at once in a *ValidationError:
+//  - ErrDanglingFKMap when Model is not registered or has no column for Field
+//  - ErrAmbiguousFKMap when Model matches several registered models
+//  - ErrFKMapKind when the kinds of ColName and Model.Field are not compatible
+//  - ErrFKMapCycle when models reference each other in a cycle. A model
+//    referencing itself, as in a tree of rows, is not considered a cycle.
+//
+// Validate is meant to be called once every model has been registered, before
+// the registry is used.
+func (reg *Registry) Validate() error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	_, err := reg.resolve()
+	return err
+}
+
+// Seal validates the registry like Validate and, if it is valid, seals it so
+// no more models may be registered. Register returns an ErrSealed problem for
+// every model registered after sealing.
+func (reg *Registry) Seal() error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, err := reg.resolve(); err != nil {
+		return err
+	}
+
+	reg.sealed = true
+	return nil
+}
+
+// ForeignKeys returns the resolved foreign keys declared by a registered model
+// in field order. An error is returned if the registry does not validate.
+func (reg *Registry) ForeignKeys(model interface{}) ([]ForeignKey, error) {
+	t, err := reg.table(model)
+	if err != nil {
+		return nil, err
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	graph, err := reg.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	fks := make([]ForeignKey, len(graph[t]))
+	for i, fk := range graph[t] {
+		fks[i] = ForeignKey{fk}
+	}
+	return fks, nil
+}
+
+// resolve returns the foreign-key graph of the registry, building it if no
+// model has been registered since it was last built. reg.mu must be held for
+// writing.
+func (reg *Registry) resolve() (map[*table][]foreignKey, error) {
+	if reg.graph != nil {
+		return reg.graph, nil
+	}
+
+	tables := make([]*table, 0, len(reg.modMap))
+	for _, t := range reg.modMap {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return typeName(tables[i].model) < typeName(tables[j].model)
+	})
+
+	errs := &ValidationError{}
+	graph := make(map[*table][]foreignKey, len(tables))
+	for _, t := range tables {
+		for _, c := range t.cols {
+			if !c.fk {
+				continue
+			}
+			if fk, ok := reg.resolveForeignKey(errs, tables, t, c); ok {
+				graph[t] = append(graph[t], fk)
+			}
+		}
+	}
+
+	findCycles(errs, tables, graph)
+	if len(errs.Errors) > 0 {
+		return nil, errs
+	}
+
+	reg.graph = graph
+	return graph, nil
+}
+
+// resolveForeignKey resolves the foreign-key map of column c of table t
+// against tables, recording any problem in errs.
+func (reg *Registry) resolveForeignKey(errs *ValidationError, tables []*table, t *table, c column) (foreignKey, bool) {
+	modelName := t.model.Name()
+	segment := "fkmap=" + c.colRelation
+	add := func(kind error, format string, args ...interface{}) {
+		errs.Errors = append(errs.Errors, &FieldError{
+			Model:   modelName,
+			Field:   c.fieldName,
+			Segment: segment,
+			Kind:    kind,
+			msg:     fmt.Sprintf(format, args...),
+		})
+	}
+
+	parts := strings.SplitN(c.colRelation, ".", 3)
+	fk := foreignKey{from: t, col: c}
+	for _, lc := range t.cols {
+		if lc.colName == parts[0] {
+			fk.fkCol = lc
+		}
+	}
+
+	var candidates []*table
+	for _, rt := range tables {
+		if rt.model.Name() == parts[1] {
+			candidates = append(candidates, rt)
+		}
+	}
+
+	// Prefer a model declared alongside the referencing model
+	if len(candidates) > 1 {
+		var local []*table
+		for _, rt := range candidates {
+			if rt.model.PkgPath() == t.model.PkgPath() {
+				local = append(local, rt)
+			}
+		}
+		if len(local) == 1 {
+			candidates = local
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		add(ErrDanglingFKMap,
+			`Foreign-key map on "%s.%s" references model "%s" which has not been registered`,
+			modelName, c.fieldName, parts[1])
+		return fk, false
+	case 1:
+		fk.to = candidates[0]
+	default:
+		names := make([]string, len(candidates))
+		for i, rt := range candidates {
+			names[i] = typeName(rt.model)
+		}
+		add(ErrAmbiguousFKMap,
+			`Foreign-key map on "%s.%s" references model "%s" which is ambiguous between %s`,
+			modelName, c.fieldName, parts[1], strings.Join(names, ", "))
+		return fk, false
+	}
+
+	found := false
+	for _, rc := range fk.to.cols {
+		if rc.fieldName == parts[2] {
+			fk.refCol = rc
+			found = true
+		}
+	}
+	if !found {
+		add(ErrDanglingFKMap,
+			`Foreign-key map on "%s.%s" references field "%s.%s" which is not mapped to a column`,
+			modelName, c.fieldName, parts[1], parts[2])
+		return fk, false
+	}
+
+	lk := valueKind(t.model.FieldByIndex(fk.fkCol.index).Type)
+	rk := valueKind(fk.to.model.FieldByIndex(fk.refCol.index).Type)
+	if kindClass(lk) != kindClass(rk) {
+		add(ErrFKMapKind,
+			`Foreign-key column "%s" on "%s.%s" is of kind %s but references "%s.%s" of kind %s`,
+			parts[0], modelName, c.fieldName, lk, parts[1], parts[2], rk)
+		return fk, false
+	}
+
+	return fk, true
+}
+
+// findCycles records an ErrFKMapCycle problem in errs for every cycle of
+// foreign keys between distinct tables in graph.
+func findCycles(errs *ValidationError, tables []*table, graph map[*table][]foreignKey) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*table]int, len(tables))
+	var path []*table
+
+	var visit func(t *table)
+	visit = func(t *table) {
+		state[t] = visiting
+		path = append(path, t)
+		for _, fk := range graph[t] {
+			switch {
+			case fk.to == t:
+				// Self references are allowed
+			case state[fk.to] == visiting:
+				start := 0
+				for path[start] != fk.to {
+					start++
+				}
+				names := make([]string, 0, len(path)-start+1)
+				for _, ct := range path[start:] {
+					names = append(names, ct.model.Name())
+				}
+				names = append(names, fk.to.model.Name())
+				errs.Errors = append(errs.Errors, &FieldError{
+					Model:   t.model.Name(),
+					Field:   fk.col.fieldName,
+					Segment: "fkmap=" + fk.col.colRelation,
+					Kind:    ErrFKMapCycle,
+					msg:     fmt.Sprintf("Foreign-key maps form a cycle: %s", strings.Join(names, " -> ")),
+				})
+			case state[fk.to] == unvisited:
+				visit(fk.to)
+			}
+		}
+		path = path[:len(path)-1]
+		state[t] = visited
+	}
+
+	for _, t := range tables {
+		if state[t] == unvisited {
+			visit(t)
+		}
+	}
+}
+
+// valueKind returns the kind of the value held by a model field of type rt,
+// looking through pointers and nullable wrappers such as sql.NullInt64 which
+// pair a value with a Valid flag.
+func valueKind(rt reflect.Type) reflect.Kind {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() == reflect.Struct && rt.NumField() == 2 && rt.Field(1).Name == "Valid" {
+		return valueKind(rt.Field(0).Type)
+	}
+	return rt.Kind()
+}
+
+// kindClass groups kinds that may be stored in compatible column types.
+func kindClass(k reflect.Kind) reflect.Kind {
+	switch k {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint
+	case reflect.Float32:
+		return reflect.Float64
+	}
+	return k
+}
@@ -0,0 +1,156 @@
+package rdb
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+type relAuthor struct {
+	ID       int64  `db:"database=blog,table=authors,col=id,pk,ai"`
+	ParentID *int64 `db:"col=parent_id,null"`
+	Parent   int64  `db:"col=parent,fkmap=parent_id.relAuthor.ID"`
+}
+
+type relPost struct {
+	ID       int64         `db:"database=blog,table=posts,col=id,pk,ai"`
+	AuthorID sql.NullInt64 `db:"col=author_id,null"`
+	Author   int64         `db:"col=author,fkmap=author_id.relAuthor.ID"`
+}
+
+type relDangling struct {
+	ID     int64 `db:"database=blog,table=dangling,col=id,pk"`
+	UserID int64 `db:"col=user_id"`
+	User   int64 `db:"col=user,fkmap=user_id.relNobody.ID"`
+}
+
+type relBadField struct {
+	ID       int64 `db:"database=blog,table=bad_field,col=id,pk"`
+	AuthorID int64 `db:"col=author_id"`
+	Author   int64 `db:"col=author,fkmap=author_id.relAuthor.Missing"`
+}
+
+type relBadKind struct {
+	ID       int64  `db:"database=blog,table=bad_kind,col=id,pk"`
+	AuthorID string `db:"col=author_id"`
+	Author   int64  `db:"col=author,fkmap=author_id.relAuthor.ID"`
+}
+
+type relCycleA struct {
+	ID  int64 `db:"database=blog,table=cycle_a,col=id,pk"`
+	BID int64 `db:"col=b_id"`
+	B   int64 `db:"col=b,fkmap=b_id.relCycleB.ID"`
+}
+
+type relCycleB struct {
+	ID  int64 `db:"database=blog,table=cycle_b,col=id,pk"`
+	AID int64 `db:"col=a_id"`
+	A   int64 `db:"col=a,fkmap=a_id.relCycleA.ID"`
+}
+
+func TestValidateResolvesForeignKeys(t *testing.T) {
+	reg := NewRegistry()
+	for _, m := range []interface{}{relPost{}, relAuthor{}} {
+		if e := reg.Register(m); e != nil {
+			t.Fatalf("Not expecting error registering %T but got: %s", m, e.Error())
+		}
+	}
+
+	if e := reg.Validate(); e != nil {
+		t.Fatalf("Not expecting error on Validate but got: %s", e.Error())
+	}
+
+	fks, err := reg.ForeignKeys(relPost{})
+	if err != nil {
+		t.Fatalf("Not expecting error on ForeignKeys but got: %s", err.Error())
+	}
+
+	if len(fks) != 1 {
+		t.Fatalf("Expected exactly one foreign key, got %d", len(fks))
+	}
+
+	fk := fks[0]
+	if fk.Model().Table().Name() != "posts" || fk.Column().Name() != "author_id" {
+		t.Errorf("Expected foreign key from posts.author_id, got %s.%s", fk.Model().Table().Name(), fk.Column().Name())
+	}
+
+	if fk.References().Table().Name() != "authors" || fk.ReferencedColumn().Name() != "id" {
+		t.Errorf("Expected foreign key to authors.id, got %s.%s",
+			fk.References().Table().Name(), fk.ReferencedColumn().Name())
+	}
+
+	// Self references are not cycles
+	fks, _ = reg.ForeignKeys(relAuthor{})
+	if len(fks) != 1 || fks[0].References().Type() != fks[0].Model().Type() {
+		t.Errorf("Expected relAuthor to reference itself")
+	}
+}
+
+func TestValidateReportsProblems(t *testing.T) {
+	reg := NewRegistry()
+	models := []interface{}{relAuthor{}, relDangling{}, relBadField{}, relBadKind{}, relCycleA{}, relCycleB{}}
+	for _, m := range models {
+		if e := reg.Register(m); e != nil {
+			t.Fatalf("Not expecting error registering %T but got: %s", m, e.Error())
+		}
+	}
+
+	e := reg.Validate()
+	var ve *ValidationError
+	if !errors.As(e, &ve) {
+		t.Fatalf("Expected a *ValidationError, got %T: %v", e, e)
+	}
+
+	expected := []struct {
+		kind error
+		m    string
+	}{
+		{ErrDanglingFKMap, `Foreign-key map on "relBadField.Author" references field "relAuthor.Missing" which is not mapped to a column`},
+		{ErrFKMapKind, `Foreign-key column "author_id" on "relBadKind.Author" is of kind string but references "relAuthor.ID" of kind int64`},
+		{ErrDanglingFKMap, `Foreign-key map on "relDangling.User" references model "relNobody" which has not been registered`},
+		{ErrFKMapCycle, `Foreign-key maps form a cycle: relCycleA -> relCycleB -> relCycleA`},
+	}
+	if len(ve.Errors) != len(expected) {
+		t.Fatalf("Expected %d problems, got %d:\n%s", len(expected), len(ve.Errors), e.Error())
+	}
+
+	for i, fe := range ve.Errors {
+		if !errors.Is(fe, expected[i].kind) {
+			t.Errorf("Expected problem %d to be of kind %q, got %q", i, expected[i].kind, fe.Kind)
+		}
+		if fe.Error() != expected[i].m {
+			t.Errorf("Expected:\n'%s'\nGot:\n'%s'", expected[i].m, fe.Error())
+		}
+	}
+
+	if _, err := reg.ForeignKeys(relAuthor{}); !errors.Is(err, ErrFKMapCycle) {
+		t.Errorf("Expected ForeignKeys to report validation problems, got %v", err)
+	}
+}
+
+func TestSeal(t *testing.T) {
+	reg := NewRegistry()
+	if e := reg.Register(relDangling{}); e != nil {
+		t.Fatalf("Not expecting error registering relDangling but got: %s", e.Error())
+	}
+
+	if e := reg.Seal(); !errors.Is(e, ErrDanglingFKMap) {
+		t.Fatalf("Expected Seal to fail on dangling foreign key, got %v", e)
+	}
+
+	if e := reg.Register(relAuthor{}); e != nil {
+		t.Fatalf("Expected registry not to be sealed after failed Seal, got: %s", e.Error())
+	}
+
+	reg = NewRegistry()
+	if e := reg.Register(relAuthor{}); e != nil {
+		t.Fatalf("Not expecting error registering relAuthor but got: %s", e.Error())
+	}
+
+	if e := reg.Seal(); e != nil {
+		t.Fatalf("Not expecting error on Seal but got: %s", e.Error())
+	}
+
+	e := reg.Register(relPost{})
+	expectProblem(t, e, ErrSealed, "Cannot register relPost struct, the registry has been sealed")
+}
@@ -0,0 +1,41 @@
+package rdb
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// fieldTargets returns the scan destinations of columns cols of model struct
+// value v, for use with sql.Rows.Scan, and a function to call once a row has
+// been scanned successfully.
+//
+// Nullable columns mapped to fields that can not hold NULL themselves, i.e.
+// fields that are neither pointers nor sql.Scanner implementations, are
+// scanned through a pointer and set to the zero value of the field on NULL.
+func fieldTargets(v reflect.Value, cols []column) ([]interface{}, func()) {
+	targets := make([]interface{}, len(cols))
+	var nulls []int
+	for i, c := range cols {
+		f := v.FieldByIndex(c.index)
+		if c.null && f.Kind() != reflect.Ptr && !reflect.PtrTo(f.Type()).Implements(scannerType) {
+			targets[i] = reflect.New(reflect.PtrTo(f.Type())).Interface()
+			nulls = append(nulls, i)
+			continue
+		}
+		targets[i] = f.Addr().Interface()
+	}
+
+	return targets, func() {
+		for _, i := range nulls {
+			f := v.FieldByIndex(cols[i].index)
+			p := reflect.ValueOf(targets[i]).Elem()
+			if p.IsNil() {
+				f.Set(reflect.Zero(f.Type()))
+			} else {
+				f.Set(p.Elem())
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Select runs query and scans every row of its result into the registered
+// model slice pointed to by dest, a *[]Model or *[]*Model.
+//
+// The query is parsed and analyzed, see Registry.Analyze, to learn where each
+// column of the select list comes from, so columns are matched to model
+// fields by their source rather than by the name they are given in the
+// result: "SELECT u.email AS contact FROM users u" fills the field mapped to
+// users.email. Columns are resolved as:
+//  - columns of the table of the model, referenced directly, through * and
+//    tbl.* or through a derived table, map to their field
+//  - columns of tables which are not registered and computed expressions map
+//    to the model column they are named after in the result
+// A column that can not be mapped to a field of the model is an error, and so
+// is a query whose select list or FROM clause can not be parsed or which
+// references unknown columns.
+func (r *Rdb) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return selectInto(ctx, r.Db, r.registry(), dest, query, args)
+}
+
+func selectInto(ctx context.Context, q querier, reg *Registry, dest interface{}, query string, args []interface{}) error {
+	sv := reflect.ValueOf(dest)
+	if sv.Kind() != reflect.Ptr || sv.IsNil() || sv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("Select requires a pointer to a slice of model structs, %T given", dest)
+	}
+	sv = sv.Elem()
+
+	et := sv.Type().Elem()
+	isPtr := et.Kind() == reflect.Ptr
+	if isPtr {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Struct {
+		return fmt.Errorf("Select requires a pointer to a slice of model structs, %T given", dest)
+	}
+
+	t, ok := reg.lookup(et)
+	if !ok {
+		return fmt.Errorf(`%w: "%s"`, ErrNotRegistered, typeName(et))
+	}
+
+	rows, cols, err := queryModel(ctx, q, reg, t, query, args)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		v := reflect.New(et).Elem()
+		targets, finish := fieldTargets(v, cols)
+		if err := rows.Scan(targets...); err != nil {
+			return err
+		}
+		finish()
+
+		if isPtr {
+			sv.Set(reflect.Append(sv, v.Addr()))
+		} else {
+			sv.Set(reflect.Append(sv, v))
+		}
+	}
+
+	return rows.Err()
+}
+
+// queryModel runs a SELECT query whose rows are scanned into model t,
+// returning its rows and the columns of t its result columns map to.
+func queryModel(ctx context.Context, q querier, reg *Registry, t *table, query string, args []interface{}) (*sql.Rows, []column, error) {
+	results, err := reg.analyzeSelect(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names, err := rows.Columns()
+	if err == nil {
+		var cols []column
+		if cols, err = resultFields(t, results, names); err == nil {
+			return rows, cols, nil
+		}
+	}
+	rows.Close()
+	return nil, nil, err
+}
+
+// maxCachedSelects bounds the number of analyzed queries a Registry caches.
+const maxCachedSelects = 1024
+
+// analyzeSelect parses and analyzes a SELECT query, see Analyze. A query
+// using syntax the parser does not support after its FROM clause is analyzed
+// by its select list and FROM clause only. The result columns of a query are
+// cached until a model is registered, so a query run repeatedly is only
+// parsed and analyzed once.
+func (reg *Registry) analyzeSelect(query string) ([]ResultColumn, error) {
+	reg.mu.RLock()
+	results, ok := reg.selects[query]
+	gen := reg.gen
+	reg.mu.RUnlock()
+	if ok {
+		return results, nil
+	}
+
+	stmt, err := Parse(query)
+	if errors.Is(err, ErrSyntax) {
+		// Syntax the parser does not support after the FROM clause is left to
+		// the database, analyzing the select list only
+		if sel, listErr := parseSelectList(query); listErr == nil {
+			stmt, err = sel, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(*SelectStmt)
+	if !ok {
+		return nil, fmt.Errorf("Select requires a SELECT statement, %q given", query)
+	}
+	if results, err = reg.Analyze(sel); err != nil {
+		return nil, err
+	}
+
+	reg.cacheSelect(query, gen, results)
+	return results, nil
+}
+
+// cacheSelect caches the result columns of query analyzed at generation gen
+// of the registry, unless a model was registered since and the analysis may
+// be stale.
+func (reg *Registry) cacheSelect(query string, gen uint64, results []ResultColumn) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.gen != gen {
+		return
+	}
+	if reg.selects == nil || len(reg.selects) >= maxCachedSelects {
+		reg.selects = make(map[string][]ResultColumn)
+	}
+	reg.selects[query] = results
+}
+
+// resultFields maps the result columns named names of a query to the columns
+// of table t, in result order. results are the analyzed result columns of the
+// query, which match the result when every star was expanded. A star is
+// expanded in the order of the fields of the model while the database returns
+// the columns of the table in its own order, so a result column is only
+// matched to its analyzed column when their names agree and else by name.
+func resultFields(t *table, results []ResultColumn, names []string) ([]column, error) {
+	byName := make(map[string]column, len(t.cols))
+	for _, c := range t.cols {
+		byName[c.colName] = c
+	}
+
+	positional := len(results) == len(names)
+	cols := make([]column, len(names))
+	for i, name := range names {
+		aligned := positional && strings.EqualFold(results[i].Name, name)
+		if aligned && results[i].t == t {
+			cols[i] = results[i].col
+			continue
+		}
+
+		// Columns of other registered tables never map to the model
+		if c, ok := byName[name]; ok && (!aligned || results[i].t == nil) {
+			cols[i] = c
+			continue
+		}
+
+		return nil, fmt.Errorf(`Select result column "%s" does not map to a field of model "%s"`,
+			name, typeName(t.model))
+	}
+	return cols, nil
+}
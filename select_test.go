@@ -0,0 +1,180 @@
+package rdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSelectMapsColumnsBySource(t *testing.T) {
+	db, srv := newCrudRdb(t, fakeResponse{
+		columns: []string{"contact", "id", "name"},
+		rows: [][]driver.Value{
+			{"a@example.com", int64(1), "A"},
+			{"b@example.com", int64(2), nil},
+		},
+	})
+
+	var users []crudUser
+	query := "SELECT u.email AS contact, u.id, name FROM users u WHERE u.id < ?"
+	if err := db.Select(context.Background(), &users, query, 3); err != nil {
+		t.Fatalf("Not expecting error on Select but got: %s", err.Error())
+	}
+
+	if c := srv.call(0); c.query != query || !reflect.DeepEqual(c.args, []driver.Value{int64(3)}) {
+		t.Errorf("Expected query to be run as given, got %s %v", c.query, c.args)
+	}
+
+	expected := []crudUser{
+		{ID: 1, Email: "a@example.com", Name: "A"},
+		{ID: 2, Email: "b@example.com"},
+	}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("Expected:\n%+v\nGot:\n%+v", expected, users)
+	}
+}
+
+func TestSelectStarIntoPointers(t *testing.T) {
+	db, _ := newCrudRdb(t, fakeResponse{
+		columns: []string{"group_id", "user_id", "role", "name"},
+		rows:    [][]driver.Value{{int64(1), int64(2), "admin", "Admins"}},
+	})
+
+	var ms []*crudMembership
+	query := "SELECT m.*, g.name AS name FROM memberships m JOIN groups g ON g.id = m.group_id"
+	err := db.Select(context.Background(), &ms, query)
+	if err == nil {
+		t.Fatalf("Expected error selecting a column of another table")
+	}
+
+	m := `Select result column "name" does not map to a field of model "` + typeName(reflect.TypeOf(crudMembership{})) + `"`
+	if err.Error() != m {
+		t.Errorf("Expected:\n'%s'\nGot:\n'%s'", m, err.Error())
+	}
+
+	db, _ = newCrudRdb(t, fakeResponse{
+		columns: []string{"group_id", "user_id", "role"},
+		rows:    [][]driver.Value{{int64(1), int64(2), "admin"}},
+	})
+	if err := db.Select(context.Background(), &ms, "SELECT * FROM memberships"); err != nil {
+		t.Fatalf("Not expecting error on Select but got: %s", err.Error())
+	}
+
+	if len(ms) != 1 || *ms[0] != (crudMembership{GroupID: 1, UserID: 2, Role: "admin"}) {
+		t.Errorf("Expected one membership to be selected, got %+v", ms)
+	}
+}
+
+func TestSelectDestinationErrors(t *testing.T) {
+	db, _ := newCrudRdb(t)
+	var users []crudUser
+	if err := db.Select(context.Background(), users, "SELECT id FROM users"); err == nil {
+		t.Errorf("Expected error selecting into a slice value")
+	}
+
+	var ints []int
+	if err := db.Select(context.Background(), &ints, "SELECT id FROM users"); err == nil {
+		t.Errorf("Expected error selecting into a slice of non structs")
+	}
+
+	if err := db.Select(context.Background(), &users, "DELETE FROM users"); err == nil {
+		t.Errorf("Expected error selecting with a non SELECT statement")
+	}
+
+	if err := db.Select(context.Background(), &users, "SELECT 'id FROM users"); !errors.Is(err, ErrUnterminated) {
+		t.Errorf("Expected unterminated token error selecting with an unterminated string, got %v", err)
+	}
+
+	if err := db.Select(context.Background(), &users, "SELECT u.id, missing FROM users u"); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("Expected unknown column error selecting an unknown column, got %v", err)
+	}
+}
+
+func TestSelectMySQLSyntax(t *testing.T) {
+	queries := []string{
+		"SELECT * FROM users WHERE id = ? FOR UPDATE",
+		"SELECT id, email, name FROM users WHERE id = ? FOR SHARE OF users SKIP LOCKED",
+		"SELECT * FROM users WHERE id = ? LOCK IN SHARE MODE",
+		"SELECT id, email, name FROM users WHERE name > NOW() - INTERVAL 1 DAY",
+		"SELECT id, email, name FROM users WHERE name >= DATE '2020-01-01'",
+		"SELECT id, email, name FROM users WHERE BINARY email = 'x'",
+		"SELECT id, CONVERT(email USING utf8mb4) AS email, name FROM users",
+		"SELECT id, email, GROUP_CONCAT(name ORDER BY name SEPARATOR ',') AS name FROM users GROUP BY id, email",
+		// Unsupported syntax after FROM falls back to the select list
+		"SELECT id, email, name FROM users WHERE MATCH (email) AGAINST ('x' IN BOOLEAN MODE)",
+		"SELECT id, email, name FROM users UNION SELECT id, email, name FROM users",
+	}
+	for _, query := range queries {
+		db, srv := newCrudRdb(t, fakeResponse{
+			columns: []string{"id", "email", "name"},
+			rows:    [][]driver.Value{{int64(1), "a@example.com", nil}},
+		})
+		var users []crudUser
+		if err := db.Select(context.Background(), &users, query, 1); err != nil {
+			t.Errorf("Not expecting error on Select of %q but got: %s", query, err.Error())
+			continue
+		}
+		if len(users) != 1 || users[0].Email != "a@example.com" {
+			t.Errorf("Expected a single user from %q, got %+v", query, users)
+		}
+		if c := srv.call(0); c.query != query {
+			t.Errorf("Expected query to be run as given, got %s", c.query)
+		}
+	}
+
+	// A locking read within a transaction
+	db, srv := newCrudRdb(t, fakeResponse{}, fakeResponse{
+		columns: []string{"id", "email", "name"},
+		rows:    [][]driver.Value{{int64(1), "a@example.com", nil}},
+	})
+	err := db.InTx(context.Background(), nil, func(tx *Tx) error {
+		var users []crudUser
+		return tx.Select(context.Background(), &users, queries[0], 1)
+	})
+	if err != nil {
+		t.Errorf("Not expecting error on a locking Select in a transaction but got: %s", err.Error())
+	}
+	if q := srv.queries(); len(q) != 3 || q[1] != queries[0] {
+		t.Errorf("Expected the locking read to run in the transaction, got %v", q)
+	}
+
+	// Syntax errors in the select list or FROM clause are still reported
+	db, _ = newCrudRdb(t)
+	var users []crudUser
+	for _, query := range []string{"SELECT id, FROM users", "SELECT id FROM users JOIN"} {
+		if err := db.Select(context.Background(), &users, query); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Expected syntax error on Select of %q, got %v", query, err)
+		}
+	}
+}
+
+func TestSelectStarInTableColumnOrder(t *testing.T) {
+	// The table declares name before email, unlike crudUser
+	users := fakeResponse{
+		columns: []string{"id", "name", "email"},
+		rows:    [][]driver.Value{{int64(1), "Alice", "a@example.com"}},
+	}
+	db, _ := newCrudRdb(t, users, users, users)
+	expected := crudUser{ID: 1, Email: "a@example.com", Name: "Alice"}
+
+	var selected []crudUser
+	if err := db.Select(context.Background(), &selected, "SELECT * FROM users"); err != nil {
+		t.Fatalf("Not expecting error on Select but got: %s", err.Error())
+	}
+	if len(selected) != 1 || selected[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, selected)
+	}
+
+	all, err := All[crudUser](context.Background(), db)
+	if err != nil || len(all) != 1 || all[0] != expected {
+		t.Errorf("Expected %+v from All, got %+v and error %v", expected, all, err)
+	}
+
+	for u, err := range Stream[crudUser](context.Background(), db, "SELECT u.* FROM users u") {
+		if err != nil || u != expected {
+			t.Errorf("Expected %+v from Stream, got %+v and error %v", expected, u, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package rdb
+
+import (
+	"reflect"
+	"strings"
+)
+
+// quoteIdent quotes a MySQL identifier with backticks, doubling any backtick
+// within the identifier.
+func quoteIdent(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// qualifiedName returns the quoted, database qualified name of the table,
+// `db_name`.`tbl_name`.
+func (t *table) qualifiedName() string {
+	return quoteIdent(t.dbName) + "." + quoteIdent(t.name)
+}
+
+// qualifiedColumn returns the quoted, database qualified name of column c of
+// table t, `db_name`.`tbl_name`.`col_name`.
+func qualifiedColumn(t *table, c column) string {
+	return t.qualifiedName() + "." + quoteIdent(c.colName)
+}
+
+// keyColumns returns the primary key columns of the table in key order.
+func (t *table) keyColumns() []column {
+	cols := make([]column, len(t.pk))
+	for i, c := range t.pk {
+		cols[i] = t.cols[c]
+	}
+	return cols
+}
+
+// whereKey returns the condition matching a single row by its primary key,
+// one placeholder per key column in key order, e.g. "`a` = ? AND `b` = ?".
+func (t *table) whereKey() string {
+	conds := make([]string, len(t.pk))
+	for i, c := range t.keyColumns() {
+		conds[i] = quoteIdent(c.colName) + " = ?"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// keyArgs returns the primary key values of model struct value v in key
+// order, matching the placeholders of whereKey.
+func (t *table) keyArgs(v reflect.Value) []interface{} {
+	args := make([]interface{}, len(t.pk))
+	for i, c := range t.keyColumns() {
+		args[i] = v.FieldByIndex(c.index).Interface()
+	}
+	return args
+}
+
+// insertQuery returns the statement inserting model struct value v into the
+// table and its arguments. The auto-increment column is left to the database.
+func (t *table) insertQuery(v reflect.Value) (string, []interface{}) {
+	names := make([]string, 0, len(t.cols))
+	marks := make([]string, 0, len(t.cols))
+	args := make([]interface{}, 0, len(t.cols))
+	for _, c := range t.cols {
+		if c.ai {
+			continue
+		}
+		names = append(names, quoteIdent(c.colName))
+		marks = append(marks, "?")
+		args = append(args, v.FieldByIndex(c.index).Interface())
+	}
+
+	query := "INSERT INTO " + t.qualifiedName() +
+		" (" + strings.Join(names, ", ") + ") VALUES (" + strings.Join(marks, ", ") + ")"
+	return query, args
+}
+
+// autoIncrement returns the auto-increment column of the table.
+func (t *table) autoIncrement() (column, bool) {
+	for _, c := range t.cols {
+		if c.ai {
+			return c, true
+		}
+	}
+	return column{}, false
+}
+
+// columnList returns the quoted, comma separated names of cols.
+func columnList(cols []column) string {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = quoteIdent(c.colName)
+	}
+	return strings.Join(names, ", ")
+}
+
+// getQuery returns the statement selecting every column of a single row of
+// the table by its primary key.
+func (t *table) getQuery() string {
+	return "SELECT " + columnList(t.cols) + " FROM " + t.qualifiedName() +
+		" WHERE " + t.whereKey() + " LIMIT 1"
+}
+
+// allQuery returns the statement selecting every column of every row of the
+// table.
+func (t *table) allQuery() string {
+	return "SELECT " + columnList(t.cols) + " FROM " + t.qualifiedName()
+}
+
+// updateQuery returns the statement updating every column but the primary key
+// columns of the row of model struct value v, and its arguments.
+func (t *table) updateQuery(v reflect.Value) (string, []interface{}) {
+	sets := make([]string, 0, len(t.cols))
+	args := make([]interface{}, 0, len(t.cols))
+	for _, c := range t.cols {
+		if c.pk {
+			continue
+		}
+		sets = append(sets, quoteIdent(c.colName)+" = ?")
+		args = append(args, v.FieldByIndex(c.index).Interface())
+	}
+
+	query := "UPDATE " + t.qualifiedName() + " SET " + strings.Join(sets, ", ") +
+		" WHERE " + t.whereKey()
+	return query, append(args, t.keyArgs(v)...)
+}
+
+// deleteQuery returns the statement deleting a single row of the table by its
+// primary key.
+func (t *table) deleteQuery() string {
+	return "DELETE FROM " + t.qualifiedName() + " WHERE " + t.whereKey()
+}
@@ -0,0 +1,42 @@
+package rdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuoteIdent(t *testing.T) {
+	if q := quoteIdent("users"); q != "`users`" {
+		t.Errorf("Expected: `users` Got: %s", q)
+	}
+	if q := quoteIdent("odd`name"); q != "`odd``name`" {
+		t.Errorf("Expected: `odd``name` Got: %s", q)
+	}
+}
+
+func TestCompositeKeyWhereClause(t *testing.T) {
+	defer reset()
+	type membership struct {
+		Role    string `db:"database=app,table=memberships,col=role"`
+		GroupID int    `db:"col=group_id,pk=2"`
+		UserID  int    `db:"col=user_id,pk=1"`
+	}
+	if e := Register(membership{}); e != nil {
+		t.Fatalf("Not expecting error on TestCompositeKeyWhereClause but got: %s", e.Error())
+	}
+
+	tbl, _ := defaultRegistry.lookup(reflect.TypeOf(membership{}))
+	if n := tbl.qualifiedName(); n != "`app`.`memberships`" {
+		t.Errorf("Expected: `app`.`memberships` Got: %s", n)
+	}
+
+	w := "`user_id` = ? AND `group_id` = ?"
+	if tbl.whereKey() != w {
+		t.Errorf("Expected: %s Got: %s", w, tbl.whereKey())
+	}
+
+	args := tbl.keyArgs(reflect.ValueOf(membership{Role: "admin", GroupID: 7, UserID: 3}))
+	if !reflect.DeepEqual(args, []interface{}{3, 7}) {
+		t.Errorf("Expected key args [3 7], got %v", args)
+	}
+}
@@ -1,5 +1,7 @@
 package rdb
 
+import "strings"
+
 // token represents an atomic component of an SQL statements
 // For RDB purposes we're only interested in column and table identifiers
 // and aliases so that datatype and data origination/destination can be established.
@@ -72,7 +74,74 @@ const (
 	usingToken
 	orderByToken
 	groupByToken
-	unparsed // Unparsed text
+	lessThan         // <
+	greaterThan      // >
+	lessOrEqual      // <=
+	greaterOrEqual   // >=
+	notEqual         // <> or !=
+	nullSafeEqual    // <=>
+	plus             // +
+	minus            // -
+	slash            // /
+	percent          // %
+	logicalOr        // ||
+	logicalAnd       // &&
+	bang             // !
+	pipe             // |
+	ampersand        // &
+	caret            // ^
+	tilde            // ~
+	shiftLeft        // <<
+	shiftRight       // >>
+	assign           // :=
+	jsonExtract      // ->
+	jsonUnquote      // ->>
+	semicolon        // ;
+	placeholder      // ?
+	namedParam       // :name
+	userVariable     // @name, @'name' or @`name`
+	systemVariable   // @@name or @@scope.name
+	comment          // -- comment, # comment or /* comment */
+	versionedComment // /*!50700 executed by MySQL 5.7 and later */
+	optimizerHint    // /*+ BKA(t1) */
+	joinToken
+	havingToken
+	limitToken
+	offsetToken
+	andToken
+	orToken
+	xorToken
+	notToken
+	inToken
+	betweenToken
+	likeToken
+	escapeToken
+	regexpToken
+	isToken
+	nullToken
+	ascToken
+	descToken
+	caseToken
+	whenToken
+	thenToken
+	elseToken
+	endToken
+	existsToken
+	divToken
+	modToken
+	updateToken
+	deleteToken
+	replaceToken
+	intoToken
+	ignoreToken
+	quickToken
+	onDuplicateKeyUpdateToken
+	returningToken
+	hexNumber     // 0x1F or X'1F'
+	bitNumber     // 0b0101 or b'0101'
+	charsetString // _utf8mb4'text' or N'text'
+	trueToken
+	falseToken
 )
 
 const (
@@ -123,4 +192,191 @@ const (
 	usingStmt                 = "USING"
 	orderByStmt               = "ORDER BY"
 	groupByStmt               = "GROUP BY"
+	joinStmt                  = "JOIN"
+	havingStmt                = "HAVING"
+	limitStmt                 = "LIMIT"
+	offsetStmt                = "OFFSET"
+	andStmt                   = "AND"
+	orStmt                    = "OR"
+	xorStmt                   = "XOR"
+	notStmt                   = "NOT"
+	inStmt                    = "IN"
+	betweenStmt               = "BETWEEN"
+	likeStmt                  = "LIKE"
+	escapeStmt                = "ESCAPE"
+	regexpStmt                = "REGEXP"
+	isStmt                    = "IS"
+	nullStmt                  = "NULL"
+	ascStmt                   = "ASC"
+	descStmt                  = "DESC"
+	caseStmt                  = "CASE"
+	whenStmt                  = "WHEN"
+	thenStmt                  = "THEN"
+	elseStmt                  = "ELSE"
+	endStmt                   = "END"
+	existsStmt                = "EXISTS"
+	divStmt                   = "DIV"
+	modStmt                   = "MOD"
+	updateStmt                = "UPDATE"
+	deleteStmt                = "DELETE"
+	replaceStmt               = "REPLACE"
+	intoStmt                  = "INTO"
+	ignoreStmt                = "IGNORE"
+	quickStmt                 = "QUICK"
+	onDuplicateKeyUpdateStmt  = "ON DUPLICATE KEY UPDATE"
+	returningStmt             = "RETURNING"
+	trueStmt                  = "TRUE"
+	falseStmt                 = "FALSE"
 )
+
+// operators holds the operators recognized by the lexer, an operator is listed
+// before any operator it starts with so the longest operator matches.
+var operators = []struct {
+	op string
+	t  token
+}{
+	{"<=>", nullSafeEqual},
+	{"->>", jsonUnquote},
+	{"<=", lessOrEqual},
+	{">=", greaterOrEqual},
+	{"<>", notEqual},
+	{"!=", notEqual},
+	{"<<", shiftLeft},
+	{">>", shiftRight},
+	{"||", logicalOr},
+	{"&&", logicalAnd},
+	{":=", assign},
+	{"->", jsonExtract},
+	{"<", lessThan},
+	{">", greaterThan},
+	{"+", plus},
+	{"-", minus},
+	{"/", slash},
+	{"%", percent},
+	{"!", bang},
+	{"|", pipe},
+	{"&", ampersand},
+	{"^", caret},
+	{"~", tilde},
+	{";", semicolon},
+	{"?", placeholder},
+}
+
+// keywordDef is a keyword and the words it is written with.
+type keywordDef struct {
+	t     token
+	words []string
+}
+
+// maxKeywordLen is the length of the longest word of a keyword.
+const maxKeywordLen = len(sqlCalcFoundRowsStmt)
+
+// keywords holds the keywords recognized by the lexer by their first word.
+var keywords = make(map[string][]keywordDef)
+
+// keywordText holds the text of every keyword token.
+var keywordText = map[token]string{
+	selectToken:                selectStmt,
+	insertToken:                insertStmt,
+	fromToken:                  fromStmt,
+	partitionToken:             partitionStmt,
+	asToken:                    asStmt,
+	straightJoinToken:          straightJoinStmt,
+	crossJoinToken:             crossJoinStmt,
+	innerJoinToken:             innerJoinStmt,
+	ojToken:                    ojStmt,
+	naturalJoinToken:           naturalJoinStmt,
+	naturalLeftJoinToken:       naturalLeftJoinStmt,
+	naturalLeftOuterJoinToken:  naturalLeftOuterJoinStmt,
+	naturalRightJoinToken:      naturalRightJoinStmt,
+	naturalRightOuterJoinToken: naturalRightOuterJoinStmt,
+	leftJoinToken:              leftJoinStmt,
+	leftOuterJoinToken:         leftOuterJoinStmt,
+	rightJoinToken:             rightJoinStmt,
+	rightOuterJoinToken:        rightOuterJoinStmt,
+	useIndexToken:              useIndexStmt,
+	useKeyToken:                useKeyStmt,
+	ignoreIndexToken:           ignoreIndexStmt,
+	ignoreKeyToken:             ignoreKeyStmt,
+	forceIndexToken:            forceIndexStmt,
+	forceKeyToken:              forceKeyStmt,
+	forJoinToken:               forJoinStmt,
+	forOrderByToken:            forOrderByStmt,
+	forGroupByToken:            forGroupByStmt,
+	whereToken:                 whereStmt,
+	valuesToken:                valuesStmt,
+	setToken:                   setStmt,
+	defaultToken:               defaultStmt,
+	allToken:                   allStmt,
+	distinctToken:              distinctStmt,
+	highPriorityToken:          highPriorityStmt,
+	lowPriorityToken:           lowPriorityStmt,
+	delayedToken:               delayedStmt,
+	maxStatementTimeToken:      maxStatementTimeStmt,
+	sqlSmallResultToken:        sqlSmallResultStmt,
+	sqlBigResultToken:          sqlBigResultStmt,
+	sqlBufferResultToken:       sqlBufferResultStmt,
+	sqlCacheToken:              sqlCacheStmt,
+	sqlNoCacheToken:            sqlNoCacheStmt,
+	sqlCalcFoundRowsToken:      sqlCalcFoundRowsStmt,
+	onToken:                    onStmt,
+	usingToken:                 usingStmt,
+	orderByToken:               orderByStmt,
+	groupByToken:               groupByStmt,
+	joinToken:                  joinStmt,
+	havingToken:                havingStmt,
+	limitToken:                 limitStmt,
+	offsetToken:                offsetStmt,
+	andToken:                   andStmt,
+	orToken:                    orStmt,
+	xorToken:                   xorStmt,
+	notToken:                   notStmt,
+	inToken:                    inStmt,
+	betweenToken:               betweenStmt,
+	likeToken:                  likeStmt,
+	escapeToken:                escapeStmt,
+	regexpToken:                regexpStmt,
+	isToken:                    isStmt,
+	nullToken:                  nullStmt,
+	ascToken:                   ascStmt,
+	descToken:                  descStmt,
+	caseToken:                  caseStmt,
+	whenToken:                  whenStmt,
+	thenToken:                  thenStmt,
+	elseToken:                  elseStmt,
+	endToken:                   endStmt,
+	existsToken:                existsStmt,
+	divToken:                   divStmt,
+	modToken:                   modStmt,
+	updateToken:                updateStmt,
+	deleteToken:                deleteStmt,
+	replaceToken:               replaceStmt,
+	intoToken:                  intoStmt,
+	ignoreToken:                ignoreStmt,
+	quickToken:                 quickStmt,
+	onDuplicateKeyUpdateToken:  onDuplicateKeyUpdateStmt,
+	returningToken:             returningStmt,
+	trueToken:                  trueStmt,
+	falseToken:                 falseStmt,
+}
+
+// charsets holds the character sets which may introduce a string literal, as
+// in _utf8mb4'text'.
+var charsets = map[string]bool{
+	"armscii8": true, "ascii": true, "big5": true, "binary": true, "cp1250": true,
+	"cp1251": true, "cp1256": true, "cp1257": true, "cp850": true, "cp852": true,
+	"cp866": true, "cp932": true, "dec8": true, "eucjpms": true, "euckr": true,
+	"gb18030": true, "gb2312": true, "gbk": true, "geostd8": true, "greek": true,
+	"hebrew": true, "hp8": true, "keybcs2": true, "koi8r": true, "koi8u": true,
+	"latin1": true, "latin2": true, "latin5": true, "latin7": true, "macce": true,
+	"macroman": true, "sjis": true, "swe7": true, "tis620": true, "ucs2": true,
+	"ujis": true, "utf16": true, "utf16le": true, "utf32": true, "utf8": true,
+	"utf8mb3": true, "utf8mb4": true,
+}
+
+func init() {
+	for t, kw := range keywordText {
+		words := strings.Fields(kw)
+		keywords[words[0]] = append(keywords[words[0]], keywordDef{t, words})
+	}
+}
@@ -0,0 +1,260 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tx is a transaction started by Rdb.InTx. It runs the same model operations
+// as Rdb within the transaction, and is a Session for Get, All, Query,
+// QueryCursor and Stream.
+type Tx struct {
+	Tx *sql.Tx
+
+	reg     *Registry
+	depth   int   // Number of open savepoints
+	aborted error // Deadlock which rolled back the transaction
+}
+
+// RetryPolicy sets how Rdb.InTx runs a transaction again when it fails on a
+// deadlock, MySQL error 1213, or a lock wait timeout, MySQL error 1205. The
+// zero value runs a transaction once.
+type RetryPolicy struct {
+	// Attempts is the most times a transaction is run.
+	Attempts int
+
+	// Delay returns the time to wait before the given retry, 1 being the
+	// first. Retries run at once when nil.
+	Delay func(retry int) time.Duration
+}
+
+// InTx runs fn in a transaction started with opts, which may be nil. The
+// transaction is committed when fn returns nil and rolled back when fn
+// returns an error or panics, the panic being raised again once rolled back.
+//
+// When fn or the commit fails on a deadlock or a lock wait timeout the whole
+// transaction is run again as set by Rdb.Retry, so fn must not have effects
+// outside of the transaction it can not repeat. The policy is that of the Rdb
+// and can not be set per call, use an Rdb of the same Db with another Retry
+// to run some transactions differently.
+func (r *Rdb) InTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	for retry := 1; ; retry++ {
+		err := r.runTx(ctx, opts, fn)
+		if err == nil || retry >= r.Retry.Attempts || !isRetryable(err) {
+			return err
+		}
+
+		var delay time.Duration
+		if r.Retry.Delay != nil {
+			delay = r.Retry.Delay(retry)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// runTx runs fn in a single transaction.
+func (r *Rdb) runTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *Tx) error) (err error) {
+	sqlTx, err := r.Db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	tx := &Tx{Tx: sqlTx, reg: r.registry()}
+
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	err = fn(tx)
+	if err == nil && tx.aborted != nil {
+		// A nested InTx failed on a deadlock which rolled back the whole
+		// transaction, the statements run since are not part of it
+		err = tx.aborted
+	}
+	if err != nil {
+		// A transaction whose context is done is already rolled back
+		if rbErr := sqlTx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w, and rolling back failed: %v", err, rbErr)
+		}
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+// InTx runs fn within a savepoint of the transaction. The savepoint is
+// released when fn returns nil, and the transaction is rolled back to it when
+// fn returns an error or panics, leaving the work done before the savepoint
+// to be committed or rolled back by the enclosing InTx.
+//
+// A deadlock rolls back the whole transaction rather than to the savepoint.
+// The deadlock is returned, and so is it by every later statement of the
+// transaction, which no longer exists. The enclosing Rdb.InTx retries the
+// transaction even when the error is handled and its fn returns nil. A lock
+// wait timeout only rolls back the statement which timed out, and is rolled
+// back to the savepoint as any other error.
+func (tx *Tx) InTx(ctx context.Context, fn func(tx *Tx) error) error {
+	if tx.aborted != nil {
+		return tx.aborted
+	}
+	tx.depth++
+	defer func() { tx.depth-- }()
+	name := fmt.Sprintf("rdb_sp%d", tx.depth)
+
+	if _, err := tx.Tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			if tx.aborted == nil {
+				tx.Tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			}
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if isDeadlock(err) && tx.aborted == nil {
+			tx.aborted = err
+		}
+		if tx.aborted != nil {
+			// The savepoint was rolled back with the transaction
+			return err
+		}
+		if _, rbErr := tx.Tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return fmt.Errorf("%w, and rolling back failed: %v", err, rbErr)
+		}
+		return err
+	}
+	if tx.aborted != nil {
+		return tx.aborted
+	}
+	_, err := tx.Tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// Insert inserts a registered model within the transaction, see Rdb.Insert.
+func (tx *Tx) Insert(ctx context.Context, model interface{}) error {
+	if tx.aborted != nil {
+		return tx.aborted
+	}
+	return insert(ctx, tx.Tx, tx.reg, model)
+}
+
+// Get loads a registered model by primary key within the transaction, see
+// Rdb.Get.
+func (tx *Tx) Get(ctx context.Context, model interface{}, keys ...interface{}) error {
+	if tx.aborted != nil {
+		return tx.aborted
+	}
+	return get(ctx, tx.Tx, tx.reg, model, keys)
+}
+
+// Update updates the row of a registered model within the transaction, see
+// Rdb.Update.
+func (tx *Tx) Update(ctx context.Context, model interface{}) (int64, error) {
+	if tx.aborted != nil {
+		return 0, tx.aborted
+	}
+	return update(ctx, tx.Tx, tx.reg, model)
+}
+
+// Delete deletes the row of a registered model within the transaction, see
+// Rdb.Delete.
+func (tx *Tx) Delete(ctx context.Context, model interface{}) (int64, error) {
+	if tx.aborted != nil {
+		return 0, tx.aborted
+	}
+	return remove(ctx, tx.Tx, tx.reg, model)
+}
+
+// Select runs a query within the transaction and scans its rows into a
+// registered model slice, see Rdb.Select.
+func (tx *Tx) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	if tx.aborted != nil {
+		return tx.aborted
+	}
+	return selectInto(ctx, tx.Tx, tx.reg, dest, query, args)
+}
+
+func (tx *Tx) session() (querier, *Registry, error) {
+	return tx.Tx, tx.reg, tx.aborted
+}
+
+// isRetryable reports whether err, or an error it wraps, is a MySQL deadlock
+// or lock wait timeout.
+func isRetryable(err error) bool {
+	return isMySQLError(err, 1213, 1205)
+}
+
+// isDeadlock reports whether err, or an error it wraps, is a MySQL deadlock.
+func isDeadlock(err error) bool {
+	return isMySQLError(err, 1213)
+}
+
+// isMySQLError reports whether err, or an error it wraps, is a MySQL error
+// numbered one of codes. Driver errors are recognized by a numeric Number
+// field, as the MySQLError of github.com/go-sql-driver/mysql, or else by
+// their message. Errors joined by errors.Join or wrapped by several %w verbs
+// are all looked at.
+func isMySQLError(err error, codes ...int) bool {
+	if err == nil {
+		return false
+	}
+	v := reflect.Indirect(reflect.ValueOf(err))
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("Number"); f.IsValid() {
+			var n int64
+			switch kindClass(f.Kind()) {
+			case reflect.Int:
+				n = f.Int()
+			case reflect.Uint:
+				n = int64(f.Uint())
+			default:
+				n = -1
+			}
+			if n >= 0 {
+				for _, code := range codes {
+					if n == int64(code) {
+						return true
+					}
+				}
+				return false
+			}
+		}
+	}
+	// Error 1213: ... or Error 1213 (40001): ...
+	for _, code := range codes {
+		prefix := "Error " + strconv.Itoa(code)
+		if rest := strings.TrimPrefix(err.Error(), prefix); len(rest) < len(err.Error()) &&
+			(rest == "" || rest[0] == ':' || rest[0] == ' ') {
+			return true
+		}
+	}
+
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return isMySQLError(e.Unwrap(), codes...)
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			if isMySQLError(err, codes...) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package rdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mysqlError mimics the error type of the MySQL driver.
+type mysqlError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *mysqlError) Error() string {
+	return fmt.Sprintf("Error %d: %s", e.Number, e.Message)
+}
+
+// codeError is a driver error with a signed error number.
+type codeError struct{ Number int }
+
+func (e codeError) Error() string {
+	return fmt.Sprintf("Code %d", e.Number)
+}
+
+func TestInTxCommits(t *testing.T) {
+	db, srv := newCrudRdb(t, fakeResponse{}, fakeResponse{lastInsertID: 5}, fakeResponse{
+		columns: []string{"id", "email", "name"},
+		rows:    [][]driver.Value{{int64(5), "a@example.com", nil}},
+	})
+
+	u := crudUser{Email: "a@example.com"}
+	err := db.InTx(context.Background(), nil, func(tx *Tx) error {
+		if err := tx.Insert(context.Background(), &u); err != nil {
+			return err
+		}
+		_, err := Get[crudUser](context.Background(), tx, u.ID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Not expecting error on InTx but got: %s", err.Error())
+	}
+
+	queries := srv.queries()
+	if len(queries) != 4 || queries[0] != "BEGIN" || queries[3] != "COMMIT" {
+		t.Errorf("Expected the statements to run in a committed transaction, got %v", queries)
+	}
+}
+
+func TestInTxRollsBack(t *testing.T) {
+	db, srv := newCrudRdb(t)
+	failed := errors.New("failed")
+	err := db.InTx(context.Background(), nil, func(tx *Tx) error {
+		tx.Delete(context.Background(), &crudUser{ID: 1})
+		return failed
+	})
+	if err != failed {
+		t.Errorf("Expected the error of the transaction, got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("Expected the panic to be raised again, got %v", p)
+			}
+		}()
+		db.InTx(context.Background(), nil, func(tx *Tx) error {
+			panic("boom")
+		})
+	}()
+
+	expected := []string{"BEGIN", "DELETE FROM `app`.`users` WHERE `id` = ?", "ROLLBACK", "BEGIN", "ROLLBACK"}
+	if q := srv.queries(); !reflect.DeepEqual(q, expected) {
+		t.Errorf("Expected:\n%v\nGot:\n%v", expected, q)
+	}
+}
+
+func TestInTxSavepoints(t *testing.T) {
+	db, srv := newCrudRdb(t)
+	failed := errors.New("failed")
+	err := db.InTx(context.Background(), nil, func(tx *Tx) error {
+		err := tx.InTx(context.Background(), func(tx *Tx) error {
+			return tx.InTx(context.Background(), func(tx *Tx) error {
+				return failed
+			})
+		})
+		if err != failed {
+			t.Errorf("Expected the error of the nested transaction, got %v", err)
+		}
+		return tx.InTx(context.Background(), func(tx *Tx) error { return nil })
+	})
+	if err != nil {
+		t.Fatalf("Not expecting error on InTx but got: %s", err.Error())
+	}
+
+	expected := []string{
+		"BEGIN",
+		"SAVEPOINT rdb_sp1", "SAVEPOINT rdb_sp2", "ROLLBACK TO SAVEPOINT rdb_sp2", "ROLLBACK TO SAVEPOINT rdb_sp1",
+		"SAVEPOINT rdb_sp1", "RELEASE SAVEPOINT rdb_sp1",
+		"COMMIT",
+	}
+	if q := srv.queries(); !reflect.DeepEqual(q, expected) {
+		t.Errorf("Expected:\n%v\nGot:\n%v", expected, q)
+	}
+}
+
+func TestInTxRetries(t *testing.T) {
+	deadlock := &mysqlError{1213, "Deadlock found when trying to get lock"}
+	db, srv := newCrudRdb(t,
+		fakeResponse{}, fakeResponse{err: deadlock}, fakeResponse{},
+		fakeResponse{}, fakeResponse{err: fmt.Errorf("Error 1205: Lock wait timeout exceeded")}, fakeResponse{},
+		fakeResponse{}, fakeResponse{rowsAffected: 1}, fakeResponse{},
+	)
+	var retries []int
+	db.Retry = RetryPolicy{Attempts: 3, Delay: func(retry int) time.Duration {
+		retries = append(retries, retry)
+		return 0
+	}}
+
+	runs := 0
+	err := db.InTx(context.Background(), nil, func(tx *Tx) error {
+		runs++
+		_, err := tx.Update(context.Background(), &crudUser{ID: 1})
+		return err
+	})
+	if err != nil || runs != 3 || !reflect.DeepEqual(retries, []int{1, 2}) {
+		t.Errorf("Expected success on the third run, got %d runs, retries %v and error %v", runs, retries, err)
+	}
+	if n := len(srv.queries()); n != 9 {
+		t.Errorf("Expected 9 statements, got %d", n)
+	}
+
+	// Other errors and exhausted attempts are returned
+	db.Retry.Attempts = 2
+	failed := errors.New("failed")
+	for _, e := range []error{failed, fmt.Errorf("Update: %w", deadlock)} {
+		runs = 0
+		err := db.InTx(context.Background(), nil, func(tx *Tx) error {
+			runs++
+			return e
+		})
+		if !errors.Is(err, e) {
+			t.Errorf("Expected error %v, got %v", e, err)
+		}
+		if expected := map[bool]int{true: 1, false: 2}[e == failed]; runs != expected {
+			t.Errorf("Expected %d runs on %v, got %d", expected, e, runs)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{&mysqlError{Number: 1205}, true},
+		{fmt.Errorf("Wrapped: %w", &mysqlError{Number: 1213}), true},
+		{&mysqlError{Number: 1062}, false},
+		{errors.New("Error 1213 (40001): Deadlock found"), true},
+		{errors.New("Error 1062: Duplicate entry '1213'"), false},
+		{errors.New("Error 12130: Unknown"), false},
+		{errors.Join(errors.New("Rolling back"), &mysqlError{Number: 1213}), true},
+		{fmt.Errorf("Update %w: %w", errors.New("failed"), &mysqlError{Number: 1205}), true},
+		{fmt.Errorf("Update: %w", errors.Join(&mysqlError{Number: 1062}, errors.New("Error 1205: Lock wait timeout"))), true},
+		{errors.Join(&mysqlError{Number: 1062}, errors.New("failed")), false},
+		{codeError{1213}, true},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if r := isRetryable(tt.err); r != tt.retryable {
+			t.Errorf("Expected %v to be retryable %v, got %v", tt.err, tt.retryable, r)
+		}
+	}
+}
+
+func TestInTxRetriesSwallowedNestedDeadlock(t *testing.T) {
+	deadlock := &mysqlError{1213, "Deadlock found when trying to get lock"}
+	db, srv := newCrudRdb(t,
+		fakeResponse{}, fakeResponse{}, fakeResponse{err: deadlock}, fakeResponse{},
+		fakeResponse{}, fakeResponse{}, fakeResponse{}, fakeResponse{}, fakeResponse{}, fakeResponse{},
+	)
+	db.Retry.Attempts = 2
+
+	var nested, outer []error
+	err := db.InTx(context.Background(), nil, func(tx *Tx) error {
+		nested = append(nested, tx.InTx(context.Background(), func(tx *Tx) error {
+			_, err := tx.Update(context.Background(), &crudUser{ID: 1})
+			return err
+		}))
+		// The nested error is handled and the transaction carries on
+		_, err := tx.Update(context.Background(), &crudUser{ID: 2})
+		outer = append(outer, err)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Not expecting error once retried but got: %s", err.Error())
+	}
+	if len(nested) != 2 || nested[0] != deadlock || nested[1] != nil {
+		t.Errorf("Expected a deadlock then success of the nested transaction, got %v", nested)
+	}
+	if len(outer) != 2 || outer[0] != deadlock || outer[1] != nil {
+		t.Errorf("Expected the deadlock then success of the statement after it, got %v", outer)
+	}
+
+	// No statement runs once the transaction is rolled back by the deadlock
+	update := "UPDATE `app`.`users` SET `email` = ?, `name` = ? WHERE `id` = ?"
+	expected := []string{
+		"BEGIN", "SAVEPOINT rdb_sp1", update, "ROLLBACK",
+		"BEGIN", "SAVEPOINT rdb_sp1", update, "RELEASE SAVEPOINT rdb_sp1", update, "COMMIT",
+	}
+	if q := srv.queries(); !reflect.DeepEqual(q, expected) {
+		t.Errorf("Expected:\n%v\nGot:\n%v", expected, q)
+	}
+
+	// Without retries the deadlock is returned by every later statement and
+	// by the transaction
+	db, srv = newCrudRdb(t, fakeResponse{}, fakeResponse{}, fakeResponse{err: deadlock}, fakeResponse{})
+	var after []error
+	err = db.InTx(context.Background(), nil, func(tx *Tx) error {
+		tx.InTx(context.Background(), func(tx *Tx) error {
+			_, err := tx.Delete(context.Background(), &crudUser{ID: 1})
+			return err
+		})
+		after = append(after,
+			tx.Insert(context.Background(), &crudUser{}),
+			tx.Get(context.Background(), &crudUser{}, 1),
+			tx.Select(context.Background(), &[]crudUser{}, "SELECT * FROM app.users"),
+			tx.InTx(context.Background(), func(tx *Tx) error { return nil }),
+		)
+		_, err := Query[crudUser](context.Background(), tx, "SELECT * FROM app.users")
+		after = append(after, err)
+		_, err = QueryCursor[crudUser](context.Background(), tx, "SELECT * FROM app.users")
+		after = append(after, err)
+		return nil
+	})
+	if err != deadlock {
+		t.Errorf("Expected the deadlock to be returned, got %v", err)
+	}
+	for i, err := range after {
+		if err != deadlock {
+			t.Errorf("Expected the deadlock from statement %d after it, got %v", i, err)
+		}
+	}
+	expected = []string{"BEGIN", "SAVEPOINT rdb_sp1", "DELETE FROM `app`.`users` WHERE `id` = ?", "ROLLBACK"}
+	if q := srv.queries(); !reflect.DeepEqual(q, expected) {
+		t.Errorf("Expected:\n%v\nGot:\n%v", expected, q)
+	}
+}
+
+func TestInTxNestedLockWaitTimeout(t *testing.T) {
+	timeout := &mysqlError{1205, "Lock wait timeout exceeded; try restarting transaction"}
+	db, srv := newCrudRdb(t,
+		fakeResponse{}, fakeResponse{}, fakeResponse{err: timeout}, fakeResponse{}, fakeResponse{}, fakeResponse{},
+	)
+
+	var nested error
+	err := db.InTx(context.Background(), nil, func(tx *Tx) error {
+		nested = tx.InTx(context.Background(), func(tx *Tx) error {
+			_, err := tx.Update(context.Background(), &crudUser{ID: 1})
+			return err
+		})
+		// Only the statement was rolled back, the transaction carries on
+		_, err := tx.Update(context.Background(), &crudUser{ID: 2})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Not expecting error but got: %s", err.Error())
+	}
+	if nested != timeout {
+		t.Errorf("Expected the lock wait timeout from the nested transaction, got %v", nested)
+	}
+
+	update := "UPDATE `app`.`users` SET `email` = ?, `name` = ? WHERE `id` = ?"
+	expected := []string{"BEGIN", "SAVEPOINT rdb_sp1", update, "ROLLBACK TO SAVEPOINT rdb_sp1", update, "COMMIT"}
+	if q := srv.queries(); !reflect.DeepEqual(q, expected) {
+		t.Errorf("Expected:\n%v\nGot:\n%v", expected, q)
+	}
+}